@@ -5,17 +5,25 @@ import (
 
 	"github.com/boombuler/barcode"
 	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/code39"
 	"github.com/boombuler/barcode/ean"
 	"github.com/boombuler/barcode/qr"
+	"github.com/boombuler/barcode/twooffive"
 	"github.com/mb0/layla"
 )
 
 func Barcode(d *layla.Node) (barcode.Barcode, error) {
 	switch d.Code.Name {
-	case "ean128":
+	case "ean128", "code128":
 		return code128.Encode(d.Data)
 	case "ean8", "ean13":
 		return ean.Encode(d.Data)
+	case "upca":
+		return ean.Encode("0" + d.Data)
+	case "itf", "itf14":
+		return twooffive.Encode(d.Data, true)
+	case "code39":
+		return code39.Encode(d.Data, false, false)
 	}
 	if d.Kind != "qrcode" {
 		return nil, fmt.Errorf("unknown code name %q", d.Code.Name)
@@ -0,0 +1,175 @@
+// Command layla provides tools to work with layla templates.
+//
+//	layla preview [-addr localhost:8080] [-font name=file.ttf]... [-i18n dir] [-default en] [dir]
+//
+// The preview command serves live html and pdf previews of the templates in dir, that reload
+// when a file changes. Fonts are registered by name and the optional i18n directory holds
+// translation catalogs named by locale like de.json, with the default locale used as fallback.
+//
+//	layla fmt [-w] [-l] file.layla...
+//
+// The fmt command prints the canonically formatted templates, or with -w rewrites the files.
+// With -l it lists the files whose formatting differs instead, both flags can be combined.
+//
+//	layla lint [-font name=file.ttf]... file.layla...
+//
+// The lint command reports suspicious template attributes and exits with status 1 if any are
+// found. Font names are only checked if fonts are registered.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+	"github.com/mb0/layla/preview"
+)
+
+const usage = `usage: layla <command> [flags] [args]
+
+commands:
+  preview   serve live previews of a template directory
+  fmt       format template files
+  lint      report suspicious template attributes
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "preview":
+		err = previewCmd(args)
+	case "fmt":
+		err = fmtCmd(args)
+	case "lint":
+		err = lintCmd(args)
+	case "help", "-h", "-help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type fontFlags []string
+
+func (f *fontFlags) String() string { return strings.Join(*f, ",") }
+func (f *fontFlags) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("font flag expects name=path got %q", v)
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+func (f fontFlags) manager() (*font.Manager, error) {
+	man := font.NewManager(72, 2, 4)
+	for _, v := range f {
+		i := strings.IndexByte(v, '=')
+		man.RegisterTTF(v[:i], v[i+1:])
+	}
+	return man, man.Err()
+}
+
+func previewCmd(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "http listen address")
+	i18n := fs.String("i18n", "", "directory of translation catalogs")
+	def := fs.String("default", "en", "default locale for translations")
+	var fonts fontFlags
+	fs.Var(&fonts, "font", "register a ttf font as name=path, can be repeated")
+	fs.Parse(args)
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	man, err := fonts.manager()
+	if err != nil {
+		return err
+	}
+	s := preview.New(dir, man)
+	if *i18n != "" {
+		cats, err := layla.LoadCatalogs(os.DirFS(*i18n), *def)
+		if err != nil {
+			return err
+		}
+		s.Catalogs = cats
+	}
+	log.Printf("serving previews of %s on http://%s/", dir, *addr)
+	return s.ListenAndServe(*addr)
+}
+
+func fmtCmd(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result to the source files")
+	list := fs.Bool("l", false, "list files whose formatting differs")
+	fs.Parse(args)
+	for _, name := range fs.Args() {
+		raw, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		res, err := layla.FormatSource(raw, name)
+		if err != nil {
+			return err
+		}
+		if !*list && !*write {
+			os.Stdout.Write(res)
+			continue
+		}
+		if bytes.Equal(raw, res) {
+			continue
+		}
+		if *list {
+			fmt.Println(name)
+		}
+		if *write {
+			if err = os.WriteFile(name, res, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func lintCmd(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	var fonts fontFlags
+	fs.Var(&fonts, "font", "register a ttf font as name=path, can be repeated")
+	fs.Parse(args)
+	man, err := fonts.manager()
+	if err != nil {
+		return err
+	}
+	var found bool
+	for _, name := range fs.Args() {
+		raw, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		ds, err := layla.Lint(raw, name, man.Names())
+		if err != nil {
+			return err
+		}
+		for _, d := range ds {
+			fmt.Println(d)
+			found = true
+		}
+	}
+	if found {
+		os.Exit(1)
+	}
+	return nil
+}
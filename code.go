@@ -0,0 +1,132 @@
+package layla
+
+import (
+	"strings"
+
+	"github.com/mb0/xelf/cor"
+)
+
+// codeDigits maps numeric barcode names to the data length including the check digit.
+var codeDigits = map[string]int{
+	"ean8":  8,
+	"ean13": 13,
+	"upca":  12,
+	"itf14": 14,
+}
+
+// qrBytes maps qrcode error correction levels to the maximum number of data bytes.
+var qrBytes = map[string]int{
+	"":  1273,
+	"l": 2953,
+	"m": 2331,
+	"q": 1663,
+	"h": 1273,
+}
+
+// checkCode validates the code name and data of barcode and qrcode nodes with the ancestors in
+// stack. The code name is normalised to lower case, the form expected by the renderers.
+// If add is true, missing check digits are computed and appended to the node data,
+// otherwise a missing or wrong check digit is an error.
+func checkCode(n *Node, stack []*Node, add bool) error {
+	if n.Code == nil {
+		return nodeErr(n, stack, cor.Errorf("%q: missing code", n.Data))
+	}
+	if name := strings.ToLower(n.Code.Name); name != n.Code.Name {
+		// the code may be shared with other nodes by styles
+		c := *n.Code
+		c.Name = name
+		n.Code = &c
+	}
+	err := validCode(n, add)
+	if err != nil {
+		return nodeErr(n, stack, cor.Errorf("%s %q: %v", n.Code.Name, n.Data, err))
+	}
+	return nil
+}
+
+func validCode(n *Node, add bool) error {
+	if n.Data == "" {
+		return cor.Errorf("empty data")
+	}
+	name := n.Code.Name
+	if n.Kind == "qrcode" {
+		max, ok := qrBytes[name]
+		if !ok {
+			return cor.Errorf("unknown error correction level")
+		}
+		if len(n.Data) > max {
+			return cor.Errorf("data exceeds %d bytes", max)
+		}
+		return nil
+	}
+	switch name {
+	case "ean8", "ean13", "upca", "itf14":
+		want := codeDigits[name]
+		if !digits(n.Data) {
+			return cor.Errorf("data must only contain digits")
+		}
+		switch len(n.Data) {
+		case want - 1:
+			if !add {
+				return cor.Errorf("missing check digit")
+			}
+			n.Data += string('0' + CheckDigit(n.Data))
+		case want:
+			c := CheckDigit(n.Data[:want-1])
+			if got := n.Data[want-1] - '0'; got != c {
+				return cor.Errorf("wrong check digit %d want %d", got, c)
+			}
+		default:
+			return cor.Errorf("data must have %d digits", want)
+		}
+	case "itf":
+		if !digits(n.Data) {
+			return cor.Errorf("data must only contain digits")
+		}
+		if len(n.Data)%2 != 0 {
+			return cor.Errorf("data must have an even number of digits")
+		}
+	case "ean128", "code128":
+		for _, r := range n.Data {
+			if r > 127 && r != fnc1 {
+				return cor.Errorf("invalid character %q", r)
+			}
+		}
+	case "code39":
+		for _, r := range n.Data {
+			if !strings.ContainsRune(code39Chars, r) {
+				return cor.Errorf("invalid character %q", r)
+			}
+		}
+	default:
+		return cor.Errorf("unknown code name")
+	}
+	return nil
+}
+
+// fnc1 is the rune used by the barcode packages to encode the ean128 function code 1.
+const fnc1 = 'ñ'
+
+const code39Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ-. $/+%"
+
+// CheckDigit returns the GS1 modulo 10 check digit for a string of digits without check digit.
+// The same check digit is used by EAN-8, EAN-13, UPC-A and ITF-14 codes.
+func CheckDigit(data string) byte {
+	var sum int
+	for i := len(data) - 1; i >= 0; i -= 2 {
+		sum += 3 * int(data[i]-'0')
+		if i > 0 {
+			sum += int(data[i-1] - '0')
+		}
+	}
+	return byte((10 - sum%10) % 10)
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
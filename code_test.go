@@ -0,0 +1,62 @@
+package layla
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckCode(t *testing.T) {
+	tests := []struct {
+		kind string
+		name string
+		data string
+		add  bool
+		want string
+		err  string
+	}{
+		{"barcode", "ean13", "4006381333931", false, "4006381333931", ""},
+		{"barcode", "ean13", "400638133393", false, "", "missing check digit"},
+		{"barcode", "ean13", "400638133393", true, "4006381333931", ""},
+		{"barcode", "ean13", "4006381333932", true, "", "wrong check digit 2 want 1"},
+		{"barcode", "ean13", "40063813339", true, "", "must have 13 digits"},
+		{"barcode", "ean8", "9638507", true, "96385074", ""},
+		{"barcode", "upca", "03600029145", true, "036000291452", ""},
+		{"barcode", "itf14", "1540014128876", true, "15400141288763", ""},
+		{"barcode", "itf", "123", false, "", "even number"},
+		{"barcode", "ean13", "40063813339a", true, "", "only contain digits"},
+		{"barcode", "ean128", "10AB19020501", false, "10AB19020501", ""},
+		{"barcode", "ean128", "10Äb", false, "", "invalid character"},
+		{"barcode", "code39", "ABC-12", false, "ABC-12", ""},
+		{"barcode", "code39", "abc", false, "", "invalid character"},
+		{"barcode", "ean99", "123", false, "", "unknown code name"},
+		{"barcode", "ean128", "", false, "", "empty data"},
+		{"qrcode", "H", "https://vendor.url/", false, "https://vendor.url/", ""},
+		{"qrcode", "x", "https://vendor.url/", false, "", "unknown error correction"},
+		{"qrcode", "h", strings.Repeat("x", 1274), false, "", "exceeds 1273 bytes"},
+		{"qrcode", "L", strings.Repeat("x", 2000), false, strings.Repeat("x", 2000), ""},
+		{"barcode", "EAN8", "9638507", true, "96385074", ""},
+	}
+	stack := []*Node{{Kind: "stage"}}
+	for _, test := range tests {
+		n := &Node{Kind: test.kind, Code: &Code{Name: test.name}, Data: test.data}
+		err := checkCode(n, stack, test.add)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s %q want error %q got %v", test.name, test.data, test.err, err)
+			} else if path := "stage/" + test.kind + ": "; !strings.Contains(err.Error(), path) {
+				t.Errorf("%s %q want error path %q got %v", test.name, test.data, path, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q error: %v", test.name, test.data, err)
+			continue
+		}
+		if n.Data != test.want {
+			t.Errorf("%s %q want data %q got %q", test.name, test.data, test.want, n.Data)
+		}
+		if want := strings.ToLower(test.name); n.Code.Name != want {
+			t.Errorf("%s %q want code name %q got %q", test.name, test.data, want, n.Code.Name)
+		}
+	}
+}
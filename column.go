@@ -0,0 +1,244 @@
+package layla
+
+import (
+	"math"
+
+	"github.com/mb0/xelf/cor"
+)
+
+// colWidth returns the column width of the columns node n for the available width w or an error.
+func colWidth(n *Node, w float64) (float64, error) {
+	if len(n.Cols) == 0 {
+		return 0, cor.Errorf("columns without cols")
+	}
+	aw := w - n.Gap*float64(len(n.Cols)-1)
+	var nw float64
+	for _, c := range n.Cols {
+		if c <= 0 {
+			nw++
+		} else {
+			aw -= c
+		}
+	}
+	res := -1.0
+	for _, c := range n.Cols {
+		if c <= 0 {
+			c = aw / nw
+		}
+		if res >= 0 && math.Abs(c-res) > 0.01 {
+			return 0, cor.Errorf("columns require equal widths")
+		}
+		res = c
+	}
+	if res <= 0 {
+		return 0, cor.Errorf("columns need available width")
+	}
+	return res, nil
+}
+
+// columnsLayout lays out the child nodes of the columns node n like a vbox with the column width.
+// Spanning child nodes use the full width.
+//
+// Columns nodes flow their content through a number of columns in page documents. The cols
+// attribute lists the column widths like for tables, zero widths share the remaining width, but
+// all columns must have the same width. The gap is used as gutter between the columns and as
+// space between the child nodes. The pager places the child nodes in the first column of the
+// page, continuing in the next column and then on the next page. Child nodes with the span
+// attribute cross all columns and start below the preceding columns. Columns nodes with the
+// balance attribute balance the column heights on the last page and before spanning nodes.
+func (l *Layouter) columnsLayout(n *Node, stack []*Node) error {
+	if len(stack) == 0 || stack[0].Kind != "page" {
+		return cor.Errorf("columns require a page")
+	}
+	stack = append(stack, n)
+	a := n.Pad.Inset(n.Calc)
+	cw, err := colWidth(n, a.W)
+	if err != nil {
+		return err
+	}
+	var h float64
+	for i, e := range n.List {
+		b := a
+		if !e.Span {
+			b.W = cw
+		}
+		eb, err := l.layout(e, b, stack)
+		if err != nil {
+			return err
+		}
+		y := eb.H
+		if i < len(n.List)-1 {
+			y += n.Gap
+		}
+		a.Y += y
+		a.H -= y
+		h += y
+	}
+	n.Calc.H = clamp(n.Calc.H, h)
+	return nil
+}
+
+// flow places child nodes of a columns node in the columns of the document pages. It is used
+// by a pager for the columns, where each new page is the next column.
+type flow struct {
+	// p is the document pager.
+	p    *pager
+	cols int
+	// cw is the column width and dx the offset from one column to the next.
+	cw, dx float64
+	// trial flows do not change the document pager and only simulate new pages.
+	trial bool
+	// pg is the current document page, it is nil for simulated pages.
+	pg       *xpage
+	idx, col int
+	top, bot float64
+	// bal is the column height on the page with index bidx if greater than zero.
+	bal  float64
+	bidx int
+}
+
+// next returns the next column page starting at offset org. It starts a new document page after
+// the last column or if the current page has no space left.
+func (f *flow) next(org float64) *xpage {
+	f.col++
+	if f.pg != nil {
+		f.bot = f.pg.Y + f.pg.H
+	}
+	if f.col >= f.cols || f.top >= f.bot {
+		f.col = 0
+		f.idx++
+		if f.trial {
+			b, _, _, _, mh := f.p.body(f.idx)
+			f.pg, f.top, f.bot = nil, b.Y+mh, b.Y+b.H
+		} else {
+			f.pg = f.p.newPage(org)
+			f.top, f.bot = f.pg.Y, f.pg.Y+f.pg.H
+		}
+	}
+	h := f.bot - f.top
+	if f.bal > 0 && f.idx == f.bidx && f.bal < h {
+		h = f.bal
+	}
+	h = math.Max(0, h)
+	b := Box{Pos: Pos{X: float64(f.col) * f.dx, Y: f.top}, Dim: Dim{W: f.cw, H: h}}
+	return &xpage{Org: org, Box: b, paths: f.p.paths, idx: f.idx}
+}
+
+// placeNote places the footnote nt referenced in the display node d in column x on the document
+// page and shortens the column to end above the footnotes.
+func (f *flow) placeNote(x *xpage, d *Node, nt *note) {
+	pg := f.p.list[x.idx]
+	f.p.placeNote(pg, d.Y+d.H-pg.Y, nt.ds, nt.H)
+	if h := pg.Y + pg.H - x.Y; h < x.H {
+		x.H = h
+	}
+}
+
+// run flows the nodes ns starting at offset org from the last document page and returns the
+// column pager or an error.
+func (f *flow) run(ns []*Node, org float64, trial bool) (*pager, error) {
+	p := f.p
+	f.trial = trial
+	f.pg = p.list[len(p.list)-1]
+	f.idx, f.col = f.pg.idx, -1
+	f.top = f.pg.Y + org - f.pg.Org
+	c := &pager{Node: p.Node, sec: p.sec, flow: f, nums: p.nums}
+	if trial {
+		c.at = make(map[*Node]int)
+		c.anchors = make(map[string]int)
+	} else {
+		c.paths, c.refs, c.at, c.anchors = p.paths, p.refs, p.at, p.anchors
+	}
+	c.newPage(org)
+	return c, c.collectAll(ns)
+}
+
+// collectColumns collects the columns node n. Spanning child nodes are collected by p, all other
+// child nodes are flowed through the columns.
+func (p *pager) collectColumns(n *Node) error {
+	cw, err := colWidth(n, n.Pad.Inset(n.Calc).W)
+	if err != nil {
+		return nodeErr(n, nil, err)
+	}
+	f := &flow{p: p, cols: len(n.Cols), cw: cw, dx: cw + n.Gap}
+	var seg []*Node
+	for i, e := range n.List {
+		if !e.Span {
+			seg = append(seg, e)
+			continue
+		}
+		if err = p.flowColumns(f, seg, n.Balance); err != nil {
+			return err
+		}
+		seg = nil
+		if err = p.collectAll(n.List[i : i+1]); err != nil {
+			return err
+		}
+	}
+	return p.flowColumns(f, seg, n.Balance)
+}
+
+// flowColumns flows the nodes ns through the columns of flow f and continues the document after
+// the longest column on the last page. If balance is true the smallest column height on the last
+// page that does not need another page is used.
+func (p *pager) flowColumns(f *flow, ns []*Node, balance bool) error {
+	if len(ns) == 0 {
+		return nil
+	}
+	first, last := ns[0], ns[len(ns)-1]
+	org := first.Calc.Y - getMargin(first).T
+	end := last.Calc.Y + last.Calc.H + getMargin(last).B
+	f.bal = 0
+	if balance {
+		c, err := f.run(ns, org, true)
+		if err != nil {
+			return err
+		}
+		bidx := c.list[len(c.list)-1].idx
+		var lx *xpage
+		for _, x := range c.list {
+			if x.idx == bidx {
+				lx = x
+				break
+			}
+		}
+		lo, hi := math.Ceil((end-lx.Org)/float64(f.cols)), lx.H
+		f.bidx = bidx
+		for lo < hi {
+			f.bal = math.Floor((lo + hi) / 2)
+			c, err = f.run(ns, org, true)
+			if err != nil {
+				return err
+			}
+			if c.list[len(c.list)-1].idx == bidx {
+				hi = f.bal
+			} else {
+				lo = f.bal + 1
+			}
+		}
+		f.bal = hi
+	}
+	c, err := f.run(ns, org, false)
+	if err != nil {
+		return err
+	}
+	for _, x := range c.list {
+		pg := p.list[x.idx]
+		for _, d := range x.res {
+			d.X += x.X
+			pg.res = append(pg.res, d)
+		}
+	}
+	for _, x := range c.trail {
+		p.trail = append(p.trail, p.list[x.idx])
+	}
+	pg := p.list[len(p.list)-1]
+	bot := pg.Y
+	for _, x := range c.list {
+		if x.idx == pg.idx {
+			bot = math.Max(bot, x.Y+x.used())
+		}
+	}
+	pg.Org = end - (bot - pg.Y)
+	return nil
+}
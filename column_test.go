@@ -0,0 +1,56 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestColumns(t *testing.T) {
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`(page w:200 h:100 (vbox
+			(columns gap:10 cols:[0 0] balance:true
+				(rect h:20) (rect h:20) (rect h:20) (rect h:20)
+				(rect h:10 span:true))
+			(rect h:10)))`, []string{
+			"rect 0 0 95 20", "rect 0 30 95 20", "rect 105 0 95 20", "rect 105 30 95 20",
+			"rect 0 60 200 10",
+			"rect 0 70 200 10",
+		}},
+		{`(page w:200 h:100 (vbox
+			(columns gap:4 cols:[0 0 0]
+				(rect h:40) (rect h:40) (rect h:40) (rect h:40)
+				(rect h:40) (rect h:40) (rect h:40))
+			(rect h:10)))`, []string{
+			"rect 0 0 64 40", "rect 0 44 64 40",
+			"rect 68 0 64 40", "rect 68 44 64 40",
+			"rect 136 0 64 40", "rect 136 44 64 40",
+			"page 0 0 0 0",
+			"rect 0 0 64 40",
+			"rect 0 40 200 10",
+		}},
+	}
+	for _, test := range tests {
+		_, got, err := pageSource(m, test.raw, func(d *Node) string {
+			return fmt.Sprintf("%s %g %g %g %g", d.Kind, d.X, d.Y, d.W, d.H)
+		})
+		if err != nil {
+			t.Errorf("for %s error: %v", test.raw, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("for %s\nwant display list:\n%s\ngot:\n%s", test.raw,
+				strings.Join(test.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+	_, _, err := pageSource(m, `(stage w:200 h:100 (columns cols:[0 0]))`, nil)
+	if err == nil || !strings.Contains(err.Error(), "columns require a page") {
+		t.Errorf("want page error got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package layla
+
+// DebugColors maps the part names of debug display nodes for the margin, padding and content
+// box of a container node to their outline color.
+var DebugColors = map[string]Color{
+	"margin":  {R: 255, G: 140},
+	"padding": {G: 160},
+	"content": {G: 120, B: 255},
+}
+
+// debugPaths returns the node paths of all nodes in the tree n.
+func debugPaths(n *Node) map[*Node]string {
+	res := make(map[*Node]string)
+	var walk func(*Node, []*Node)
+	walk = func(n *Node, stack []*Node) {
+		res[n] = NodePath(n, stack)
+		stack = append(stack, n)
+		for _, e := range n.List {
+			walk(e, stack)
+		}
+	}
+	walk(n, nil)
+	return res
+}
+
+// debugNodes returns display nodes of kind debug for the margin, padding and content box of the
+// container node n. The part and its color from DebugColors are set. The padding box has
+// the node path as data.
+func debugNodes(n *Node, path string) []*Node {
+	b := n.Calc
+	if n.loc != nil {
+		b = n.loc.Box
+	}
+	res := make([]*Node, 0, 3)
+	part := func(name string, b Box) *Node {
+		c := DebugColors[name]
+		d := &Node{Kind: "debug", Box: b, Color: &c, Part: name, Src: n.Src}
+		res = append(res, d)
+		return d
+	}
+	if m := getMargin(n); m != (Off{}) {
+		part("margin", m.Outset(b))
+	}
+	part("padding", b).Data = path
+	if n.Pad != nil && *n.Pad != (Off{}) {
+		part("content", n.Pad.Inset(b))
+	}
+	return res
+}
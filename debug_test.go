@@ -0,0 +1,52 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPageDebug(t *testing.T) {
+	n := &Node{Kind: "stage", Box: Box{Dim: Dim{W: 200, H: 100}},
+		NodeLayout: NodeLayout{Pad: &Off{L: 8, T: 8, R: 8, B: 8}},
+		List: []*Node{
+			{Kind: "vbox", NodeLayout: NodeLayout{Mar: &Off{T: 4}}, List: []*Node{
+				{Kind: "line", Box: Box{Dim: Dim{W: 40}}, Border: Border{W: 2}},
+			}},
+		}}
+	l := &Layouter{}
+	if err := l.Layout(n); err != nil {
+		t.Fatalf("layout error: %v", err)
+	}
+	draw, err := PageDebug(n)
+	if err != nil {
+		t.Fatalf("page error: %v", err)
+	}
+	var got []string
+	for _, d := range draw {
+		s := fmt.Sprintf("%s %s %g %g %g %g", d.Kind, d.Part, d.X, d.Y, d.W, d.H)
+		if d.Data != "" {
+			s += " " + d.Data
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"debug padding 0 0 200 100 stage",
+		"debug content 8 8 184 84",
+		"debug margin 8 8 184 4",
+		"debug padding 8 12 184 0 stage/vbox",
+		"line  8 12 40 0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want display list:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	for _, d := range draw[:4] {
+		if c := DebugColors[d.Part]; d.Color == nil || *d.Color != c {
+			t.Errorf("want debug color %v got %v", c, d.Color)
+		}
+	}
+	draw, err = Page(n)
+	if err != nil || len(draw) != 1 || draw[0].Kind != "line" {
+		t.Errorf("want only the line without debug got %v %v", draw, err)
+	}
+}
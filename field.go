@@ -0,0 +1,287 @@
+package layla
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mb0/xelf/cor"
+)
+
+// Fields are placeholders in the data of text and markup nodes resolved after paging:
+//
+//	µ{page}       the document page number
+//	µ{pages}      the document page count
+//	µ{spage}      the section page number
+//	µ{spages}     the section page count
+//	µ{ref name}   the document page number of the node with the anchor name
+//	µ{note id}    the mark of the footnote with the anchor id
+//
+// Fields take an optional number format: 1 for arabic numbers, the default, i and I for lower
+// and upper case roman numbers and a and A for lower and upper case letters, as in µ{page i} or
+// µ{ref intro A}. In extra, cover, header and footer nodes the tokens µP and µT are short for
+// the first two fields.
+//
+// Fields in extra, cover, header and footer nodes are resolved for each page they are drawn on.
+// Other fields are resolved for the page their node starts on. Those nodes are laid out again
+// with the resolved values by LayoutAndPage until the values are stable.
+type field struct {
+	raw, cur string
+	// notes holds the footnote references in the current value.
+	notes []noteRef
+}
+
+// maxPasses is the maximum number of layout passes used to resolve fields.
+const maxPasses = 4
+
+// pageVals holds the values to resolve fields.
+type pageVals struct {
+	page, pages   int
+	spage, spages int
+	// anchors maps anchor names to page numbers. It is nil before the first paging.
+	anchors map[string]int
+	// notes maps footnote ids to their number.
+	notes map[string]int
+}
+
+var fieldTokens = strings.NewReplacer("µP", "µ{page}", "µT", "µ{pages}")
+
+// hasFields returns whether s contains fields or, for fixed nodes, field tokens.
+func hasFields(s string, fixed bool) bool {
+	return strings.Contains(s, "µ{") || fixed && fieldTokens.Replace(s) != s
+}
+
+// expandFields returns s of fixed nodes with all fields and tokens replaced by their values in v
+// or an error.
+func expandFields(s string, v *pageVals) (string, error) {
+	return expand(fieldTokens.Replace(s), v, false)
+}
+
+// expand returns s with all fields replaced by their values in v or an error. If sep is true
+// footnote marks are preceded by the note separator.
+func expand(s string, v *pageVals, sep bool) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "µ{")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", cor.Errorf("unclosed field in %q", s)
+		}
+		b.WriteString(s[:start])
+		f := s[start+len("µ{") : start+end]
+		val, err := fieldVal(f, v)
+		if err != nil {
+			return "", err
+		}
+		if sep && strings.HasPrefix(f, "note") {
+			b.WriteRune(noteSep)
+		}
+		b.WriteString(val)
+		s = s[start+end+1:]
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+func fieldVal(f string, v *pageVals) (string, error) {
+	args := strings.Fields(f)
+	if len(args) == 0 {
+		return "", cor.Errorf("empty field")
+	}
+	var num int
+	switch args[0] {
+	case "page":
+		num = v.page
+	case "pages":
+		num = v.pages
+	case "spage":
+		num = v.spage
+	case "spages":
+		num = v.spages
+	case "ref":
+		if len(args) < 2 {
+			return "", cor.Errorf("ref field without anchor name")
+		}
+		num = 1
+		if v.anchors != nil {
+			var ok bool
+			if num, ok = v.anchors[args[1]]; !ok {
+				return "", cor.Errorf("unknown anchor %q", args[1])
+			}
+		}
+		args = args[1:]
+	case "note":
+		if len(args) != 2 {
+			return "", cor.Errorf("note field expects one footnote id")
+		}
+		num, ok := v.notes[args[1]]
+		if !ok {
+			return "", cor.Errorf("unknown footnote %q", args[1])
+		}
+		return NoteMark(num), nil
+	default:
+		return "", cor.Errorf("unknown field %q", args[0])
+	}
+	switch len(args) {
+	case 1:
+		return strconv.Itoa(num), nil
+	case 2:
+		return FormatNumber(num, args[1])
+	}
+	return "", cor.Errorf("unexpected field arguments in %q", f)
+}
+
+// FormatNumber returns num in the number format f, 1 for arabic, i and I for roman and a and A
+// for letters, or an error for unknown formats.
+func FormatNumber(num int, f string) (string, error) {
+	switch f {
+	case "1":
+		return strconv.Itoa(num), nil
+	case "i":
+		return strings.ToLower(roman(num)), nil
+	case "I":
+		return roman(num), nil
+	case "a":
+		return strings.ToLower(alpha(num)), nil
+	case "A":
+		return alpha(num), nil
+	}
+	return "", cor.Errorf("unknown number format %q", f)
+}
+
+func roman(num int) string {
+	if num <= 0 {
+		return strconv.Itoa(num)
+	}
+	var b strings.Builder
+	for _, r := range []struct {
+		v int
+		s string
+	}{{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"}} {
+		for ; num >= r.v; num -= r.v {
+			b.WriteString(r.s)
+		}
+	}
+	return b.String()
+}
+
+// alpha returns the letters A to Z for 1 to 26 continued with AA, AB and so on.
+func alpha(num int) string {
+	if num <= 0 {
+		return strconv.Itoa(num)
+	}
+	var res []byte
+	for ; num > 0; num = (num - 1) / 26 {
+		res = append([]byte{byte('A' + (num-1)%26)}, res...)
+	}
+	return string(res)
+}
+
+// prepFields expands the fields in text and markup nodes of the tree n with preliminary values
+// and keeps the field source for later passes. Fields in extra, cover, header and footer nodes
+// are only checked.
+func prepFields(n *Node) error {
+	v := &pageVals{1, 1, 1, 1, nil, noteNums(n.notes)}
+	refs := make(map[string]bool)
+	var walk func(*Node, []*Node, bool) error
+	walk = func(n *Node, stack []*Node, fixed bool) error {
+		switch n.Kind {
+		case "extra", "cover", "header", "footer":
+			fixed = true
+		case "text", "markup":
+			if n.fld != nil || !hasFields(n.Data, fixed) {
+				break
+			}
+			if fixed {
+				if _, err := expandFields(n.Data, v); err != nil {
+					return nodeErr(n, stack, err)
+				}
+				break
+			}
+			n.fld = &field{raw: n.Data}
+			if _, err := n.fld.expand(n, v); err != nil {
+				return nodeErr(n, stack, err)
+			}
+		}
+		if n.fld != nil {
+			for _, r := range n.fld.notes {
+				refs[r.mark] = true
+			}
+		}
+		stack = append(stack, n)
+		for _, e := range n.List {
+			if err := walk(e, stack, fixed); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(n, nil, false); err != nil {
+		return err
+	}
+	// references inside footnotes are not placed
+	for _, nt := range n.notes {
+		if !refs[nt.Mark] {
+			return nodeErr(nt.Node, []*Node{n}, cor.Errorf("footnote %q is not referenced", nt.ID))
+		}
+	}
+	for _, nt := range n.notes {
+		if err := walk(nt.Node, []*Node{n}, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expand sets the data of node n to the field source expanded with the values v and returns
+// whether it changed.
+func (f *field) expand(n *Node, v *pageVals) (bool, error) {
+	s, err := expand(f.raw, v, true)
+	if err != nil {
+		return false, err
+	}
+	s, f.notes, err = splitNotes(s, n.Kind == "markup")
+	if err != nil {
+		return false, err
+	}
+	if s == f.cur {
+		return false, nil
+	}
+	f.cur, n.Data = s, s
+	return true, nil
+}
+
+// resolveFields expands the fields of nodes in the tree n with the values from the paging result
+// p and returns whether any value changed.
+func resolveFields(n *Node, p *pager) (changed bool, err error) {
+	var walk func(*Node, []*Node) error
+	walk = func(n *Node, stack []*Node) error {
+		if f := n.fld; f != nil {
+			v := p.vals(p.at[n])
+			c, err := f.expand(n, &v)
+			if err != nil {
+				return nodeErr(n, stack, err)
+			}
+			changed = changed || c
+		}
+		stack = append(stack, n)
+		for _, e := range n.List {
+			if err := walk(e, stack); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err = walk(n, nil); err != nil {
+		return false, err
+	}
+	for _, nt := range n.notes {
+		if err = walk(nt.Node, []*Node{n}); err != nil {
+			return false, err
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,102 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		num  int
+		f    string
+		want string
+	}{
+		{4, "1", "4"},
+		{4, "i", "iv"},
+		{1994, "I", "MCMXCIV"},
+		{1, "a", "a"},
+		{28, "A", "AB"},
+		{0, "I", "0"},
+	}
+	for _, test := range tests {
+		got, err := FormatNumber(test.num, test.f)
+		if err != nil || got != test.want {
+			t.Errorf("format %d %s want %s got %s %v", test.num, test.f, test.want, got, err)
+		}
+	}
+	v := &pageVals{2, 9, 1, 3, map[string]int{"end": 7}, map[string]int{"nuts": 12}}
+	got, err := expandFields("µP/µ{pages I} µ{spage}/µ{spages} 5 µS/cm see "+
+		"µ{ref end a}µ{note nuts}", v)
+	if want := "2/IX 1/3 5 µS/cm see g¹²"; err != nil || got != want {
+		t.Errorf("want %q got %q %v", want, got, err)
+	}
+	for _, raw := range []string{"µ{page", "µ{}", "µ{chapter}", "µ{ref}", "µ{ref nope}",
+		"µ{page x}", "µ{page 1 2}", "µ{note}", "µ{note milk}"} {
+		if _, err := expandFields(raw, v); err == nil {
+			t.Errorf("want error for %q", raw)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`(page w:200 h:100 font.line:10
+			(footer (text 'µ{page i}'))
+			(vbox
+				(text 'see page µ{ref end}')
+				(rect h:80)
+				(text 'page µ{page} of µ{pages A}')
+				(rect h:80)
+				(rect h:10 anchor:'end')))`, []string{
+			"90 i", "0 see page 3", "90 ii", "0 page 2 of C", "90 iii",
+		}},
+		// footnotes in fields resolved on later passes keep their mark, tokens are only used
+		// in fixed nodes
+		{`(page w:200 h:100 font.line:10 (vbox
+			(text 'µ{ref end}µ{note a} 500 µS/cm')
+			(footnote anchor:'a' (text 'µ{note a} a'))
+			(rect h:80)
+			(rect h:10 anchor:'end')))`, []string{
+			"0 2¹ 500 µS/cm", "90 ¹ a",
+		}},
+	}
+	texts := func(d *Node) string {
+		if d.Kind != "text" {
+			return ""
+		}
+		return fmt.Sprintf("%g %s", d.Y, d.Data)
+	}
+	for _, test := range tests {
+		n, got, err := pageSource(m, test.raw, texts)
+		if err != nil {
+			t.Errorf("for %s error: %v", test.raw, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("for %s\nwant texts:\n%s\ngot:\n%s", test.raw,
+				strings.Join(test.want, "\n"), strings.Join(got, "\n"))
+		}
+		// the field source is kept for formatting
+		if f := Format(n); !strings.Contains(f, "µ{ref end}") {
+			t.Errorf("want field source got %s", f)
+		}
+	}
+	// units in fixed nodes are not mistaken for field tokens
+	_, got, err := pageSource(m,
+		`(page w:400 h:100 font.line:10 (header (text '5 µS/cm 2 µC µP/µT')))`, texts)
+	if want := "0 5 µS/cm 2 µC 1/1"; err != nil || strings.Join(got, "\n") != want {
+		t.Errorf("want header text %q got %q %v", want, got, err)
+	}
+	_, _, err = pageSource(m, `(page w:200 h:100 (text 'µ{ref end}') (rect h:10 anchor:'start'))`,
+		nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown anchor "end"`) {
+		t.Errorf("want anchor error got %v", err)
+	}
+}
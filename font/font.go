@@ -2,6 +2,7 @@ package font
 
 import (
 	"io/ioutil"
+	"sort"
 
 	"github.com/golang/freetype/truetype"
 	"github.com/mb0/xelf/cor"
@@ -16,6 +17,7 @@ type Key struct {
 type Src struct {
 	*truetype.Font
 	Path string
+	Data []byte
 }
 
 type Manager struct {
@@ -87,10 +89,29 @@ func (m *Manager) RegisterTTF(name string, path string) *Manager {
 	if m.ttfs == nil {
 		m.ttfs = make(map[string]*Src)
 	}
-	m.ttfs[name] = &Src{f, path}
+	m.ttfs[name] = &Src{f, path, data}
 	return m
 }
 
+// Names returns the sorted names of all registered fonts.
+func (m *Manager) Names() []string {
+	res := make([]string, 0, len(m.ttfs))
+	for name := range m.ttfs {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Source returns the registered font source with name or an error.
+func (m *Manager) Source(name string) (*Src, error) {
+	src, ok := m.ttfs[name]
+	if !ok {
+		return nil, cor.Errorf("unknown font %q", name)
+	}
+	return src, nil
+}
+
 func (m *Manager) Path(name string) (string, error) {
 	src, ok := m.ttfs[name]
 	if !ok {
@@ -0,0 +1,276 @@
+package layla
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mb0/xelf/cor"
+)
+
+const noteDigits = "⁰¹²³⁴⁵⁶⁷⁸⁹"
+
+// NoteMark returns the inline marker for the footnote number num as superscript digits.
+func NoteMark(num int) string {
+	var res []rune
+	for _, d := range strconv.Itoa(num) {
+		res = append(res, []rune(noteDigits)[d-'0'])
+	}
+	return string(res)
+}
+
+// note is a numbered footnote with the anchor id used to reference it.
+type note struct {
+	ID   string
+	Mark string
+	Node *Node
+	H    float64
+	ds   []*Node
+	done bool
+}
+
+// notePart is the part of a footnote placed on a page. The display nodes are relative to the
+// top of the part.
+type notePart struct {
+	ds []*Node
+	h  float64
+}
+
+// noteRef is the mark of a footnote reference at the offset off in the displayed text of a text
+// or markup node. The offset counts runes without white space, that is kept by line breaking.
+type noteRef struct {
+	mark string
+	off  int
+}
+
+// noteRefs holds the footnote references of a text or markup node with the offset seen by the
+// display nodes drawn so far.
+type noteRefs struct {
+	list []noteRef
+	seen int
+}
+
+// noteSep is used to find the position of footnote references in expanded text.
+const noteSep = '\uFDD0'
+
+// footnotes detaches the footnote nodes from the page tree n and returns them numbered in
+// document order. Footnotes are named by their anchor and referenced by the µ{note id} field,
+// that is resolved to the mark of the footnote. Footnotes are only supported in page nodes,
+// references in extra, cover, header and footer nodes show the mark but do not place the note.
+func footnotes(n *Node) ([]*note, error) {
+	var res []*note
+	ids := make(map[string]bool)
+	var walk func(*Node, []*Node) error
+	walk = func(n *Node, stack []*Node) error {
+		stack = append(stack, n)
+		list := n.List[:0]
+		for _, e := range n.List {
+			if e.Kind != "footnote" {
+				list = append(list, e)
+				if err := walk(e, stack); err != nil {
+					return err
+				}
+				continue
+			}
+			if stack[0].Kind != "page" {
+				return nodeErr(e, stack, cor.Errorf("footnotes require a page"))
+			}
+			if e.Anchor == "" {
+				return nodeErr(e, stack, cor.Errorf("footnote without anchor"))
+			}
+			if ids[e.Anchor] {
+				return nodeErr(e, stack, cor.Errorf("duplicate footnote %q", e.Anchor))
+			}
+			ids[e.Anchor] = true
+			if err := checkNote(e, stack); err != nil {
+				return err
+			}
+			res = append(res, &note{ID: e.Anchor, Mark: NoteMark(len(res) + 1), Node: e})
+		}
+		n.List = list
+		return nil
+	}
+	err := walk(n, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// checkNote returns an error if the footnote n contains other footnotes.
+func checkNote(n *Node, stack []*Node) error {
+	stack = append(stack, n)
+	for _, e := range n.List {
+		if e.Kind == "footnote" {
+			return nodeErr(e, stack, cor.Errorf("footnotes cannot be nested"))
+		}
+		if err := checkNote(e, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noteNums returns the footnote numbers of notes by id.
+func noteNums(notes []*note) map[string]int {
+	res := make(map[string]int, len(notes))
+	for i, nt := range notes {
+		res[nt.ID] = i + 1
+	}
+	return res
+}
+
+// splitNotes returns the expanded text s without note separators and the footnote references
+// with their offset in the displayed text. The displayed text of markup is the text of its
+// elements without the markup syntax and link urls.
+func splitNotes(s string, markup bool) (string, []noteRef, error) {
+	if !strings.ContainsRune(s, noteSep) {
+		return s, nil, nil
+	}
+	txt := s
+	if markup {
+		els, err := markupEls(s)
+		if err != nil {
+			return "", nil, err
+		}
+		var b strings.Builder
+		for _, el := range els {
+			b.WriteString(elShown(el))
+		}
+		txt = b.String()
+	}
+	var res []noteRef
+	var off int
+	for len(txt) > 0 {
+		r, size := utf8.DecodeRuneInString(txt)
+		txt = txt[size:]
+		if r == noteSep {
+			end := strings.IndexFunc(txt, func(r rune) bool { return !isNoteDigit(r) })
+			if end < 0 {
+				end = len(txt)
+			}
+			res = append(res, noteRef{txt[:end], off})
+		} else if !unicode.IsSpace(r) {
+			off++
+		}
+	}
+	return strings.ReplaceAll(s, string(noteSep), ""), res, nil
+}
+
+func isNoteDigit(r rune) bool { return strings.ContainsRune(noteDigits, r) }
+
+// nonSpace returns the number of runes in s without white space.
+func nonSpace(s string) (c int) {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			c++
+		}
+	}
+	return c
+}
+
+// layoutNotes lays out the footnotes of page n with the width of the page content box.
+func (l *Layouter) layoutNotes(n *Node) error {
+	for _, nt := range n.notes {
+		a := n.Pad.Inset(n.Calc)
+		a.Y, a.H = 0, 0
+		eb, err := l.layout(nt.Node, a, []*Node{n})
+		if err != nil {
+			return err
+		}
+		nt.H = eb.Y + eb.H
+	}
+	return nil
+}
+
+// placeNote adds the footnote ds with height h to the footnote area of page x, below the body
+// offset yb. Parts that do not fit are carried over to the next page.
+func (p *pager) placeNote(x *xpage, yb float64, ds []*Node, h float64) {
+	if avail := x.H - yb; h > avail {
+		fit, rest := splitNote(ds, avail)
+		if len(fit) == 0 && yb <= 0 && len(x.notes) == 0 {
+			// oversized parts are placed on an empty page anyway
+			fit, rest = rest, nil
+		}
+		if len(rest) > 0 {
+			p.carry(x, notePart{rest, partHeight(rest)})
+		}
+		ds, h = fit, partHeight(fit)
+	}
+	if len(ds) > 0 {
+		x.notes = append(x.notes, notePart{ds, h})
+		x.H -= h
+		x.nh += h
+	}
+}
+
+// carry places the footnote part on the page after x or keeps it pending for a new page.
+func (p *pager) carry(x *xpage, part notePart) {
+	for i, e := range p.list {
+		if e == x && i+1 < len(p.list) {
+			next := p.list[i+1]
+			p.placeNote(next, next.used(), part.ds, part.h)
+			return
+		}
+	}
+	p.pending = append(p.pending, part)
+}
+
+// used returns the bottom offset of the body content of page x without debug nodes.
+func (x *xpage) used() (h float64) {
+	for _, d := range x.res {
+		if d.Kind == "debug" {
+			continue
+		}
+		if b := d.Y + d.H - x.Y; b > h {
+			h = b
+		}
+	}
+	return h
+}
+
+// splitNote splits the footnote display nodes ds at height h. Multi-line text nodes are split
+// between lines, other nodes that do not fit are moved to the rest. The rest is moved to the top.
+func splitNote(ds []*Node, h float64) (fit, rest []*Node) {
+	for _, d := range ds {
+		if d.Y+d.H <= h {
+			fit = append(fit, d)
+			continue
+		}
+		if d.Kind == "text" && d.Y < h && d.Rot == 0 && d.Font != nil && d.Font.Line > 0 {
+			txt := strings.Split(d.Data, "\n")
+			lc := int((h - d.Y) / d.Font.Line)
+			if lc > 0 && lc < len(txt) {
+				head, tail := *d, *d
+				head.H = math.Ceil(d.Font.Line * float64(lc))
+				head.Data = strings.Join(txt[:lc], "\n")
+				tail.Y += head.H
+				tail.H -= head.H
+				tail.Data = strings.Join(txt[lc:], "\n")
+				fit = append(fit, &head)
+				rest = append(rest, &tail)
+				continue
+			}
+		}
+		rest = append(rest, d)
+	}
+	if len(rest) > 0 {
+		top := rest[0].Y
+		for _, d := range rest {
+			top = math.Min(top, d.Y)
+		}
+		for _, d := range rest {
+			d.Y -= top
+		}
+	}
+	return fit, rest
+}
+
+func partHeight(ds []*Node) (h float64) {
+	for _, d := range ds {
+		h = math.Max(h, d.Y+d.H)
+	}
+	return h
+}
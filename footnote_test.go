@@ -0,0 +1,95 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestFootnotes(t *testing.T) {
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`(page w:200 h:100 font.line:10
+			(footer h:10)
+			(vbox
+				(text 'Nuts µ{note nuts} 2m²')
+				(footnote anchor:'nuts' (text 'µ{note nuts} nuts'))
+				(rect h:50)
+				(footnote anchor:'milk' (text 'µ{note milk} one\ntwo\nthree'))
+				(text 'Milk µ{note milk}')
+				(rect h:30)))`, []string{
+			"text 0 10 Nuts ¹ 2m²",
+			"rect 10 50",
+			"text 60 10 Milk ²",
+			"text 70 10 ¹ nuts",
+			"text 80 10 ² one",
+			"page 0 0",
+			"rect 0 30",
+			"text 70 20 two|three",
+		}},
+		// notes are numbered in document order and placed in reference order
+		{`(page w:200 h:100 font.line:10 (vbox
+			(footnote anchor:'b' (text 'µ{note b} b'))
+			(footnote anchor:'a' (text 'µ{note a} a'))
+			(text 'A µ{note a}')
+			(rect h:60)
+			(text 'B µ{note b}')))`, []string{
+			"text 0 10 A ²",
+			"rect 10 60",
+			"text 70 10 B ¹",
+			"text 80 10 ² a",
+			"text 90 10 ¹ b",
+		}},
+		// notes are placed below the markup line of their reference and not of similar text
+		{`(page w:200 h:40 font.line:10 (vbox
+			(markup 'x¹\n*y*\nz\nNuts µ{note a}')
+			(footnote anchor:'a' (text 'µ{note a} a'))))`, []string{
+			"text 0 10 x¹",
+			"text 10 10 y",
+			"text 20 10 z",
+			"text 30 10 Nuts",
+			"text 30 10 ¹",
+			"page 0 0",
+			"text 30 10 ¹ a",
+		}},
+	}
+	for _, test := range tests {
+		_, got, err := pageSource(m, test.raw, func(d *Node) string {
+			return strings.TrimSpace(fmt.Sprintf("%s %g %g %s", d.Kind, d.Y, d.H,
+				strings.ReplaceAll(d.Data, "\n", "|")))
+		})
+		if err != nil {
+			t.Errorf("for %s error: %v", test.raw, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("for %s\nwant display list:\n%s\ngot:\n%s", test.raw,
+				strings.Join(test.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+	errs := []struct {
+		raw  string
+		want string
+	}{
+		{`(stage w:200 h:100 (footnote anchor:'a'))`, "footnotes require a page"},
+		{`(page w:200 h:100 (text 'µ{note a}'))`, `unknown footnote "a"`},
+		{`(page w:200 h:100 (text 'µ{note}'))`, "note field expects one footnote id"},
+		{`(page w:200 h:100 (footnote))`, "footnote without anchor"},
+		{`(page w:200 h:100 (text '¹') (footnote anchor:'a'))`, `footnote "a" is not referenced`},
+		{`(page w:200 h:100 (text 'µ{note a}') (footnote anchor:'a') (footnote anchor:'a'))`,
+			`duplicate footnote "a"`},
+		{`(page w:200 h:100 (text 'µ{note a}') (footnote anchor:'a' (footnote anchor:'b')))`,
+			"footnotes cannot be nested"},
+	}
+	for _, e := range errs {
+		_, _, err := pageSource(m, e.raw, nil)
+		if err == nil || !strings.Contains(err.Error(), e.want) {
+			t.Errorf("for %s want error %q got %v", e.raw, e.want, err)
+		}
+	}
+}
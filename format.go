@@ -0,0 +1,220 @@
+package layla
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mb0/xelf/bfr"
+)
+
+// Format returns the canonical layla source of the node tree n.
+func Format(n *Node) string {
+	var b strings.Builder
+	writeNode(&b, n, nil, 0)
+	return b.String()
+}
+
+// FormatBfr writes the node tree n to b as canonical, pretty printed layla source.
+//
+// Each node starts on a new line indented by tabs and is written with its tags in field order,
+// followed by the quoted data or the child nodes. Nodes with children end with a closing paren
+// on a separate line. Tags with zero values and values children inherit from their parent, like
+// the font pointer, align and link, are omitted. Structured values are written as lists, except
+// fonts and codes, which are written as records. Layout results are not written. Left alignment
+// is written for children of aligned parents and alignments set explicitly in the source, so
+// that executing the source results in an equal node tree.
+func FormatBfr(b bfr.B, n *Node) error {
+	_, err := b.WriteString(Format(n))
+	return err
+}
+
+func writeNode(b *strings.Builder, n, p *Node, depth int) {
+	b.WriteString(strings.Repeat("\t", depth))
+	b.WriteByte('(')
+	b.WriteString(n.Kind)
+	tag := func(name, val string) {
+		b.WriteByte(' ')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(val)
+	}
+	num := func(name string, v float64) {
+		if v != 0 {
+			tag(name, fmtNum(v))
+		}
+	}
+	num("x", n.X)
+	num("y", n.Y)
+	num("w", n.W)
+	num("h", n.H)
+	if n.Mar != nil {
+		tag("mar", fmtList(n.Mar.L, n.Mar.T, n.Mar.R, n.Mar.B))
+	}
+	if n.Pad != nil {
+		tag("pad", fmtList(n.Pad.L, n.Pad.T, n.Pad.R, n.Pad.B))
+	}
+	num("rot", float64(n.Rot))
+	if n.alignSet || p != nil && n.Align != p.Align || p == nil && n.Align != 0 {
+		tag("align", strconv.Itoa(n.Align))
+	}
+	num("gap", n.Gap)
+	if n.Sub != (Dim{}) {
+		tag("sub", fmtList(n.Sub.W, n.Sub.H))
+	}
+	if n.Font != nil && (p == nil || n.Font != p.Font) {
+		tag("font", fmtFont(n.Font))
+	}
+	if n.Border != (Border{}) {
+		br := n.Border
+		tag("border", fmtList(br.W, br.L, br.T, br.R, br.B))
+	}
+	if c := n.Color; c != nil {
+		tag("color", fmtList(float64(c.R), float64(c.G), float64(c.B)))
+	}
+	if len(n.Cols) > 0 {
+		tag("cols", fmtList(n.Cols...))
+	}
+	if n.Head {
+		tag("head", "true")
+	}
+	if n.Keep {
+		tag("keep", "true")
+	}
+	if n.Next {
+		tag("next", "true")
+	}
+	if n.Break != "" {
+		tag("break", quote(n.Break))
+	}
+	num("widows", float64(n.Widows))
+	num("orphans", float64(n.Orphans))
+	if n.On != "" {
+		tag("on", quote(n.On))
+	}
+	if n.Restart {
+		tag("restart", "true")
+	}
+	if n.Anchor != "" {
+		tag("anchor", quote(n.Anchor))
+	}
+	num("toc", float64(n.Toc))
+	if n.Span {
+		tag("span", "true")
+	}
+	if n.Balance {
+		tag("balance", "true")
+	}
+	if c := n.Code; c != nil {
+		tag("code", fmtCode(c))
+	}
+	if n.Style != "" {
+		tag("style", quote(n.Style))
+	}
+	if n.Link != "" && (p == nil || n.Link != p.Link) {
+		tag("link", quote(n.Link))
+	}
+	if isDataNode(n.Kind) {
+		data := n.Data
+		if n.fld != nil {
+			data = n.fld.raw
+		}
+		if data != "" {
+			b.WriteByte(' ')
+			b.WriteString(quote(data))
+		}
+		b.WriteString(")\n")
+		return
+	}
+	if len(n.List) == 0 || n.Kind == "toc" {
+		b.WriteString(")\n")
+		return
+	}
+	b.WriteByte('\n')
+	for _, e := range n.List {
+		writeNode(b, e, n, depth+1)
+	}
+	b.WriteString(strings.Repeat("\t", depth))
+	b.WriteString(")\n")
+}
+
+// isDataNode returns whether nodes of kind have data instead of child nodes. The children of
+// laid out text and markup nodes are generated and never written.
+func isDataNode(kind string) bool {
+	for _, k := range dataNodes {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func fmtNum(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// fmtList returns the values as list without trailing zeros but at least one value.
+func fmtList(vs ...float64) string {
+	for len(vs) > 1 && vs[len(vs)-1] == 0 {
+		vs = vs[:len(vs)-1]
+	}
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(fmtNum(v))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func fmtFont(f *Font) string {
+	var fs []string
+	if f.Name != "" {
+		fs = append(fs, "name:"+quote(f.Name))
+	}
+	if f.Size != 0 {
+		fs = append(fs, "size:"+fmtNum(f.Size))
+	}
+	if f.Line != 0 {
+		fs = append(fs, "line:"+fmtNum(f.Line))
+	}
+	return "{" + strings.Join(fs, " ") + "}"
+}
+
+func fmtCode(c *Code) string {
+	var fs []string
+	if c.Name != "" {
+		fs = append(fs, "name:"+quote(c.Name))
+	}
+	if c.Human != 0 {
+		fs = append(fs, fmt.Sprintf("human:%d", c.Human))
+	}
+	if c.Wide != 0 {
+		fs = append(fs, "wide:"+fmtNum(c.Wide))
+	}
+	return "{" + strings.Join(fs, " ") + "}"
+}
+
+// quote returns s as single quoted layla string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package layla
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func formatTree() *Node {
+	f := &Font{Name: "regular", Size: 7}
+	return &Node{Kind: "stage", Box: Box{Dim: Dim{W: 360, H: 360}}, Font: f,
+		NodeLayout: NodeLayout{Align: AlignCenter, Gap: 30, Pad: &Off{L: 30, T: 40, R: 30}},
+		List: []*Node{
+			{Kind: "vbox", Font: f, NodeLayout: NodeLayout{Align: AlignCenter}, List: []*Node{
+				{Kind: "text", Font: &Font{Name: "bold", Size: 12}, Data: "it's\nnew",
+					NodeLayout: NodeLayout{Align: AlignCenter}},
+				{Kind: "qrcode", Font: f, Code: &Code{Name: "qr", Wide: 4}, Data: "x",
+					Link: "http://a.b", NodeLayout: NodeLayout{Align: AlignLeft}},
+				{Kind: "ellipse", Font: f, Border: Border{W: 2}, Color: &Color{R: 255},
+					NodeLayout: NodeLayout{Align: AlignCenter}},
+			}},
+		},
+	}
+}
+
+func TestFormat(t *testing.T) {
+	n := formatTree()
+	want := `(stage w:360 h:360 pad:[30 40 30] align:2 gap:30 font:{name:'regular' size:7}
+	(vbox
+		(text font:{name:'bold' size:12} 'it\'s\nnew')
+		(qrcode align:0 code:{name:'qr' wide:4} link:'http://a.b' 'x')
+		(ellipse border:[2] color:[255])
+	)
+)
+`
+	if got := Format(n); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestFormatExecute(t *testing.T) {
+	n := formatTree()
+	src := Format(n)
+	got, err := ExecuteString(Env, src)
+	if err != nil {
+		t.Fatalf("execute error: %v\n%s", err, src)
+	}
+	want, _ := json.Marshal(n)
+	res, _ := json.Marshal(got)
+	if string(res) != string(want) {
+		t.Errorf("want executed tree:\n%s\ngot:\n%s", want, res)
+	}
+	if q := got.List[0].List[1]; q.Align != AlignLeft {
+		t.Errorf("want explicit left alignment got %d", q.Align)
+	}
+}
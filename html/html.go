@@ -5,6 +5,7 @@ package html
 import (
 	"encoding/base64"
 	"fmt"
+	"html"
 	"image"
 	"image/png"
 	"log"
@@ -21,31 +22,66 @@ import (
 
 // RenderBfr renders the node n as HTML to b or returns an error.
 func RenderBfr(b bfr.B, man *font.Manager, n *layla.Node) error {
-	draw, err := layla.LayoutAndPage(man, n)
+	return Renderer{Manager: man}.RenderBfr(b, n)
+}
+
+// Renderer renders layla nodes as HTML using the registered fonts of the font manager.
+type Renderer struct {
+	*font.Manager
+	// Prefix is the class name of the label wrapper and the prefix of generated font families.
+	// It defaults to "layla". Characters other than ascii letters, digits, dashes and underscores
+	// are replaced by dashes.
+	Prefix string
+	// ID is used as prefix for the id attribute of the label wrapper of each page, if not empty.
+	ID string
+	// FontURL returns the url for the registered font with name and file path.
+	// Fonts are embedded as data urls if FontURL is nil.
+	FontURL func(name, path string) string
+	// Debug draws dashed outlines of the margin, padding and content boxes of container nodes
+	// labeled with the node path.
+	Debug bool
+}
+
+// RenderBfr renders the node n as HTML to b or returns an error.
+func (r Renderer) RenderBfr(b bfr.B, n *layla.Node) error {
+	l := layla.NewLayouter(r.Manager)
+	l.Debug = r.Debug
+	draw, err := l.LayoutAndPage(n)
 	if err != nil {
 		return err
 	}
-	b.WriteString(`<style>
-@font-face {
-	font-family: 'regular';
-	src: url('font/Go-Regular.ttf') format('truetype');
-}
-.layla {
+	dim, err := layla.Rotate(draw, n.Dim, n.Rot)
+	if err != nil {
+		return err
+	}
+	pre := r.prefix()
+	b.WriteString("<style>\n")
+	err = r.writeFontFaces(b)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, `.%s {
 	position: relative;
 	background-color: white;
 	margin: 10mm;
 }
-.layla div {
+.%[1]s div {
 	position: absolute;
 	box-sizing: border-box;
 }</style>
-`)
+`, pre)
+	var page int
 	for i, d := range draw {
 		if i == 0 || d.Kind == "page" {
 			if i > 0 {
 				b.WriteString("</div>\n")
 			}
-			fmt.Fprintf(b, `<div class="layla" style="width:%gmm;height:%gmm">`+"\n", n.W/8, n.H/8)
+			page++
+			fmt.Fprintf(b, `<div class="%s"`, pre)
+			if r.ID != "" {
+				fmt.Fprintf(b, ` id="%s-%d"`, html.EscapeString(r.ID), page)
+			}
+			fmt.Fprintf(b, ` style="width:%gmm;height:%gmm">`+"\n", dim.W/8, dim.H/8)
 			if d.Kind == "page" {
 				continue
 			}
@@ -54,41 +90,41 @@ func RenderBfr(b bfr.B, man *font.Manager, n *layla.Node) error {
 		switch d.Kind {
 		case "ellipse":
 			writeBox(b, d.Box)
-			fmt.Fprintf(b, "border:%gmm solid black;", d.Border.W/8)
+			fmt.Fprintf(b, "border:%gmm solid %s;", d.Border.W/8, cssColor(d.Color))
 			b.WriteString(`border-radius: 50%">`)
 		case "line":
 			if d.W == 0 {
 				writeBox(b, d.Box)
-				fmt.Fprintf(b, "border-left:%gmm solid black;", d.Border.W/8)
+				fmt.Fprintf(b, "border-left:%gmm solid %s;", d.Border.W/8, cssColor(d.Color))
 			} else if d.H == 0 {
 				writeBox(b, d.Box)
-				fmt.Fprintf(b, "border-top:%gmm solid black;", d.Border.W/8)
+				fmt.Fprintf(b, "border-top:%gmm solid %s;", d.Border.W/8, cssColor(d.Color))
 			} else {
 				hyp := math.Sqrt(d.W*d.W + d.H*d.H)
-				deg := math.Asin(d.H/hyp) * 180 / math.Pi
+				deg := math.Atan2(d.H, d.W) * 180 / math.Pi
 				writeBox(b, layla.Box{d.Pos, layla.Dim{math.Ceil(hyp), 0}})
-				fmt.Fprintf(b, "border-top:%gmm solid black;", d.Border.W/8)
+				fmt.Fprintf(b, "border-top:%gmm solid %s;", d.Border.W/8, cssColor(d.Color))
 				fmt.Fprintf(b, "transform:rotate(%gdeg);", math.Round(deg*10)/10)
 				b.WriteString(`transform-origin:top left;`)
 			}
 			b.WriteString(`">`)
 		case "rect":
 			writeBox(b, d.Box)
-			fmt.Fprintf(b, "border:%gmm solid black;", d.Border.W/8)
+			fmt.Fprintf(b, "border:%gmm solid %s;", d.Border.W/8, cssColor(d.Color))
 			b.WriteString(`">`)
 		case "text":
-			fmt.Fprintf(b, "left:%gmm;", (d.X-2)/8)
-			fmt.Fprintf(b, "top:%gmm;", d.Y/8)
-			fmt.Fprintf(b, "width:%gmm;", (d.W+4)/8)
-			fmt.Fprintf(b, "height:%gmm;", d.H/8)
-			fmt.Fprintf(b, "font-family: %s;", d.Font.Name)
-			fmt.Fprintf(b, "font-size: %gpt;", d.Font.Size)
-			fmt.Fprintf(b, "line-height: %gmm;", d.Font.Line/8)
-			if d.Font.Style&mark.B != 0 {
-				fmt.Fprintf(b, "font-weight:bold;")
+			c := layla.Unrot(d.Box, d.Rot)
+			fmt.Fprintf(b, "left:%gmm;", (c.X-2)/8)
+			fmt.Fprintf(b, "top:%gmm;", c.Y/8)
+			fmt.Fprintf(b, "width:%gmm;", (c.W+4)/8)
+			fmt.Fprintf(b, "height:%gmm;", c.H/8)
+			writeRot(b, d.Rot)
+			if d.Color != nil {
+				fmt.Fprintf(b, "color:%s;", cssColor(d.Color))
 			}
+			r.writeFont(b, d.Font)
 			if d.Border.W > 0 {
-				fmt.Fprintf(b, "border:%gmm solid black;", d.Border.W/8)
+				fmt.Fprintf(b, "border:%gmm solid %s;", d.Border.W/8, cssColor(d.Color))
 			}
 			switch d.Align {
 			case 1:
@@ -97,9 +133,20 @@ func RenderBfr(b bfr.B, man *font.Manager, n *layla.Node) error {
 				fmt.Fprintf(b, "text-align: center;")
 			}
 			b.WriteString(`">`)
-			b.WriteString(strings.ReplaceAll(d.Data, "\n", "<br>\n"))
-		case "barcode", "qrcode":
+			b.WriteString(strings.ReplaceAll(html.EscapeString(d.Data), "\n", "<br>\n"))
+		case "debug":
 			writeBox(b, d.Box)
+			fmt.Fprintf(b, "border:1px dashed %s;pointer-events:none;", cssColor(d.Color))
+			b.WriteString(`">`)
+			if d.Data != "" {
+				fmt.Fprintf(b, `<span style="font:6px sans-serif;color:%s;background:white">`,
+					cssColor(d.Color))
+				b.WriteString(html.EscapeString(d.Data))
+				b.WriteString(`</span>`)
+			}
+		case "barcode", "qrcode":
+			writeBox(b, layla.Unrot(d.Box, d.Rot))
+			writeRot(b, d.Rot)
 			b.WriteString(`">`)
 			err = writeBarcode(b, d)
 			if err != nil {
@@ -111,6 +158,101 @@ func RenderBfr(b bfr.B, man *font.Manager, n *layla.Node) error {
 	b.WriteString(`</div>`)
 	return nil
 }
+
+// prefix returns the sanitised class name prefix. Names starting with a digit are prefixed
+// with an underscore to be valid css class selectors.
+func (r Renderer) prefix() string {
+	if r.Prefix == "" {
+		return "layla"
+	}
+	res := cssName(r.Prefix)
+	if res[0] >= '0' && res[0] <= '9' {
+		res = "_" + res
+	}
+	return res
+}
+
+// family returns the css font family name for a registered font name.
+func (r Renderer) family(name string) string {
+	if name == "" {
+		name = "default"
+	}
+	return cssName(r.prefix() + "-" + name)
+}
+
+// cssName returns s with characters other than ascii letters, digits, dashes and underscores
+// replaced by dashes.
+func cssName(s string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+			return c
+		}
+		return '-'
+	}, s)
+}
+
+// writeFontFaces writes a font face rule for each font registered with the font manager.
+func (r Renderer) writeFontFaces(b bfr.B) error {
+	for _, name := range r.Names() {
+		src, err := r.Source(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "@font-face {\n\tfont-family: '%s';\n\tsrc: url('", r.family(name))
+		if r.FontURL != nil {
+			b.WriteString(cssEscape(r.FontURL(name, src.Path)))
+		} else {
+			b.WriteString("data:font/ttf;base64,")
+			b.WriteString(base64.StdEncoding.EncodeToString(src.Data))
+		}
+		b.WriteString("') format('truetype');\n}\n")
+	}
+	return nil
+}
+
+// cssEscape returns s escaped for a quoted css string inside a html style element.
+// Quotes, backslashes, angle brackets, ampersands and control characters are written as css
+// hex escapes.
+func cssEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c < 0x20, c == 0x7f, strings.ContainsRune(`'"\<>&`, c):
+			fmt.Fprintf(&b, "\\%x ", c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// writeFont writes the font family, size and style of text nodes.
+func (r Renderer) writeFont(b bfr.B, f *layla.Font) {
+	if f == nil {
+		return
+	}
+	fmt.Fprintf(b, "font-family: '%s';", r.family(f.Name))
+	fmt.Fprintf(b, "font-size: %gpt;", f.Size)
+	fmt.Fprintf(b, "line-height: %gmm;", f.Line/8)
+	if f.Style&mark.B != 0 {
+		b.WriteString("font-weight:bold;")
+	}
+	if f.Style&mark.I != 0 {
+		b.WriteString("font-style:italic;")
+	}
+	if f.Style&mark.A != 0 {
+		b.WriteString("color:#0645ad;text-decoration:underline;")
+	}
+}
+
+func cssColor(c *layla.Color) string {
+	if c == nil {
+		return "black"
+	}
+	return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+}
+
 func writeBox(b bfr.B, d layla.Box) {
 	fmt.Fprintf(b, "left:%gmm;", d.X/8)
 	fmt.Fprintf(b, "top:%gmm;", d.Y/8)
@@ -118,17 +260,24 @@ func writeBox(b bfr.B, d layla.Box) {
 	fmt.Fprintf(b, "height:%gmm;", d.H/8)
 }
 
+func writeRot(b bfr.B, rot int) {
+	if rot != 0 {
+		fmt.Fprintf(b, "transform:rotate(%ddeg);", rot)
+	}
+}
+
 func writeBarcode(b bfr.B, d *layla.Node) error {
 	img, err := bcode.Barcode(d)
 	if err != nil {
 		return err
 	}
-	img, err = barcode.Scale(img, int(d.W), int(d.H))
+	c := layla.RotDim(d.Dim, d.Rot)
+	img, err = barcode.Scale(img, int(c.W), int(c.H))
 	if err != nil {
-		log.Printf("scale barcode %g %g", d.W, d.H)
+		log.Printf("scale barcode %g %g", c.W, c.H)
 		return err
 	}
-	fmt.Fprintf(b, `<img style="width:%gmm; height:%gmm" src="`, d.W/8, d.H/8)
+	fmt.Fprintf(b, `<img style="width:%gmm; height:%gmm" src="`, c.W/8, c.H/8)
 	err = writeDataURL(b, img)
 	if err != nil {
 		return err
@@ -145,5 +294,5 @@ func writeDataURL(b bfr.B, img image.Image) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return enc.Close()
 }
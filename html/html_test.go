@@ -0,0 +1,136 @@
+package html
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+)
+
+func TestRenderer(t *testing.T) {
+	man := font.NewManager(72, 2, 4).
+		RegisterTTF("regular", "../testdata/font/Go-Regular.ttf").
+		RegisterTTF("bold", "../testdata/font/Go-Bold.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	n := &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 400, H: 200}},
+		Font: &layla.Font{Name: "regular", Size: 8},
+		List: []*layla.Node{
+			{Kind: "text", Data: "Fish & <Chips>"},
+			{Kind: "markup", Box: layla.Box{Pos: layla.Pos{Y: 50}}, Data: "*bold* _italic_"},
+		},
+	}
+	r := Renderer{Manager: man, Prefix: "lbl", ID: "preview",
+		FontURL: func(name, path string) string {
+			if name == "bold" {
+				return "/fonts/b'</style>.ttf"
+			}
+			return "/fonts/" + name + ".ttf"
+		},
+	}
+	var b strings.Builder
+	err := r.RenderBfr(&b, n)
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"font-family: 'lbl-bold';\n\tsrc: url('/fonts/b\\27 \\3c /style\\3e .ttf')",
+		"font-family: 'lbl-regular';\n\tsrc: url('/fonts/regular.ttf')",
+		".lbl div {",
+		`<div class="lbl" id="preview-1" style="width:50mm;height:25mm">`,
+		">Fish &amp; &lt;Chips&gt;</div>",
+		"font-weight:bold;\">bold</div>",
+		"font-style:italic;\">italic</div>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<Chips>") {
+		t.Errorf("text must be escaped")
+	}
+	b.Reset()
+	err = RenderBfr(&b, man, n)
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !strings.Contains(b.String(), "src: url('data:font/ttf;base64,") {
+		t.Errorf("want fonts embedded as data urls")
+	}
+	// prefixes are sanitised for class names and selectors
+	for pre, want := range map[string]string{`a"b <c>`: "a-b--c-", "1st": "_1st"} {
+		b.Reset()
+		err = Renderer{Manager: man, Prefix: pre}.RenderBfr(&b, n)
+		if err != nil {
+			t.Fatalf("render error: %v", err)
+		}
+		got := b.String()
+		for _, w := range []string{"." + want + " div {", `<div class="` + want + `"`,
+			"font-family: '" + want + "-regular';"} {
+			if !strings.Contains(got, w) {
+				t.Errorf("want %q in:\n%s", w, got)
+			}
+		}
+		if !strings.Contains(want, pre) && strings.Contains(got, pre) {
+			t.Errorf("prefix %q must be sanitised", pre)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rot  int
+		want []string
+	}{
+		{0, []string{
+			`<div class="layla" style="width:50mm;height:25mm">`,
+			`<div style="left:5mm;top:2mm;width:10mm;height:5mm;border`,
+			`<div style="left:24.75mm;top:10mm;width:5.875mm;height:3.375mm;font-family`,
+		}},
+		{90, []string{
+			`<div class="layla" style="width:25mm;height:50mm">`,
+			`<div style="left:18mm;top:5mm;width:5mm;height:10mm;border`,
+			`<div style="left:10.375mm;top:26mm;width:5.875mm;height:3.375mm;transform:rotate(90deg);`,
+		}},
+		{180, []string{
+			`<div class="layla" style="width:50mm;height:25mm">`,
+			`<div style="left:35mm;top:18mm;width:10mm;height:5mm;border`,
+			`<div style="left:19.375mm;top:11.625mm;width:5.875mm;height:3.375mm;transform:rotate(180deg);`,
+		}},
+		{270, []string{
+			`<div class="layla" style="width:25mm;height:50mm">`,
+			`<div style="left:2mm;top:35mm;width:5mm;height:10mm;border`,
+			`<div style="left:8.75mm;top:20.625mm;width:5.875mm;height:3.375mm;transform:rotate(270deg);`,
+		}},
+	}
+	for _, test := range tests {
+		n := &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 400, H: 200}},
+			Font: &layla.Font{Size: 8},
+			List: []*layla.Node{
+				{Kind: "rect", Box: layla.Box{Pos: layla.Pos{X: 40, Y: 16},
+					Dim: layla.Dim{W: 80, H: 40}}},
+				{Kind: "text", Box: layla.Box{Pos: layla.Pos{X: 200, Y: 80}}, Data: "Test"},
+			},
+		}
+		n.Rot = test.rot
+		var b strings.Builder
+		err := Renderer{Manager: man}.RenderBfr(&b, n)
+		if err != nil {
+			t.Errorf("rot %d render error: %v", test.rot, err)
+			continue
+		}
+		got := b.String()
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("rot %d want %q in:\n%s", test.rot, want, got)
+			}
+		}
+	}
+}
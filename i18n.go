@@ -0,0 +1,223 @@
+package layla
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/mb0/xelf/cor"
+	"github.com/mb0/xelf/exp"
+	"github.com/mb0/xelf/lit"
+	"github.com/mb0/xelf/typ"
+)
+
+// Catalogs holds translation message catalogs by locale tag.
+//
+// The tr form translates a message key for the locale selected with Env. The optional record
+// argument provides values for placeholders and the count n used to select the plural form:
+//
+//	(tr 'packed')                         Verpackt am:
+//	(tr 'pieces' {n:3})                   3 Stück
+//	(tr 'best' {date:(fmt_date $now)})    Mindestens haltbar bis 05.10.2019
+//
+// Keys missing in the catalog of a locale are looked up in the default locale.
+type Catalogs struct {
+	Default string
+	Locales map[string]Catalog
+}
+
+// Catalog maps message keys to messages.
+type Catalog map[string]Message
+
+// Message maps plural categories zero, one and other to message text with {name} placeholders.
+// Messages are read from json strings, that are used for all counts, or objects of forms:
+//
+//	{"packed": "Verpackt am:", "pieces": {"one": "{n} Stück", "other": "{n} Stück"}}
+type Message map[string]string
+
+func (m *Message) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*m = Message{"other": s}
+		return nil
+	}
+	var forms map[string]string
+	if err := json.Unmarshal(b, &forms); err != nil {
+		return cor.Errorf("message must be a string or object of plural forms")
+	}
+	if forms["other"] == "" {
+		return cor.Errorf("message without other form")
+	}
+	*m = forms
+	return nil
+}
+
+// LoadCatalogs reads all json files in fsys as catalogs named by the locale tag in the file
+// name like de.json or de-AT.json and returns the catalogs with default locale def.
+func LoadCatalogs(fsys fs.FS, def string) (*Catalogs, error) {
+	names, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalogs{Default: def, Locales: make(map[string]Catalog, len(names))}
+	for _, name := range names {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		var cat Catalog
+		err = json.Unmarshal(b, &cat)
+		if err != nil {
+			return nil, cor.Errorf("catalog %s: %v", name, err)
+		}
+		c.Locales[strings.ToLower(strings.TrimSuffix(path.Base(name), ".json"))] = cat
+	}
+	if c.Locales[strings.ToLower(def)] == nil {
+		return nil, cor.Errorf("no catalog for default locale %s", def)
+	}
+	return c, nil
+}
+
+// Env returns the layla environment for locale tag with the tr form and formatting functions.
+func (c *Catalogs) Env(tag string) exp.Builtin {
+	tr := &exp.Spec{typ.Form("tr", []typ.Param{{Name: "key", Type: typ.Str}, {Name: "arg?"}}),
+		exp.ResolverFunc(c.resolveTr(tag))}
+	return append(exp.Builtin{func(sym string) *exp.Spec {
+		if sym == "tr" {
+			return tr
+		}
+		return nil
+	}}, LocaleEnv(tag)...)
+}
+
+// Translate returns the message for key in the locale tag with the placeholders replaced by
+// the values in args. The plural form is selected by the count arg n. Numbers are formatted
+// for the locale of the catalog the message was found in.
+func (c *Catalogs) Translate(tag, key string, args lit.Keyer) (string, error) {
+	m, loc := c.message(tag, key)
+	if m == nil {
+		return "", cor.Errorf("no translation for %q in %s", key, tag)
+	}
+	text := m["other"]
+	n, err := argKey(args, "n")
+	if err != nil {
+		return "", err
+	}
+	if v, ok := n.(lit.Numeric); ok {
+		form := loc.Plural(v.Num())
+		if v.Num() == 0 && m["zero"] != "" {
+			form = "zero"
+		}
+		if t := m[form]; t != "" {
+			text = t
+		}
+	}
+	return replaceArgs(loc, text, args)
+}
+
+// message returns the message for key in the catalog for tag, its parent tags or the default
+// catalog and the locale used for the plural rules.
+func (c *Catalogs) message(tag, key string) (Message, *Locale) {
+	tags := []string{strings.ToLower(strings.Replace(tag, "_", "-", -1)), strings.ToLower(c.Default)}
+	for _, t := range tags {
+		for t != "" {
+			if m, ok := c.Locales[t][key]; ok {
+				loc := FindLocale(t)
+				if loc == nil {
+					loc = Locales["en"]
+				}
+				return m, loc
+			}
+			i := strings.LastIndexByte(t, '-')
+			if i < 0 {
+				break
+			}
+			t = t[:i]
+		}
+	}
+	return nil, nil
+}
+
+// argKey returns the value of key in args or nil.
+func argKey(args lit.Keyer, key string) (lit.Lit, error) {
+	if args == nil {
+		return nil, nil
+	}
+	for _, k := range args.Keys() {
+		if k == key {
+			return args.Key(key)
+		}
+	}
+	return nil, nil
+}
+
+// replaceArgs returns text with all {name} placeholders replaced by the values in args.
+// Numbers are formatted using locale loc.
+func replaceArgs(loc *Locale, text string, args lit.Keyer) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			break
+		}
+		name := text[start+1 : start+end]
+		v, err := argKey(args, name)
+		if err != nil {
+			return "", err
+		}
+		if v == nil {
+			return "", cor.Errorf("missing placeholder argument %s", name)
+		}
+		b.WriteString(text[:start])
+		switch v := v.(type) {
+		case lit.Str:
+			b.WriteString(string(v))
+		case lit.Numeric:
+			b.WriteString(loc.FormatUnit(v.Num(), ""))
+		default:
+			b.WriteString(v.String())
+		}
+		text = text[start+end+1:]
+	}
+	b.WriteString(text)
+	return b.String(), nil
+}
+
+func (c *Catalogs) resolveTr(tag string) exp.ResolverFunc {
+	return func(ctx *exp.Ctx, env exp.Env, x *exp.Call, hint typ.Type) (exp.El, error) {
+		if len(x.Args) < 1 || len(x.Args) > 2 {
+			return nil, cor.Errorf("%s expects a key and optional record argument", x)
+		}
+		var key string
+		var args lit.Keyer
+		for i, arg := range x.Args {
+			el, err := ctx.Eval(env, arg, typ.Void)
+			if err != nil {
+				return nil, err
+			}
+			a, ok := el.(*exp.Atom)
+			if !ok {
+				return nil, cor.Errorf("%s argument %s unresolved", x, arg)
+			}
+			if i == 0 {
+				s, ok := a.Lit.(lit.Str)
+				if !ok {
+					return nil, cor.Errorf("%s expects a key string got %s", x, a.Lit)
+				}
+				key = string(s)
+			} else if args, ok = a.Lit.(lit.Keyer); !ok {
+				return nil, cor.Errorf("%s expects a record argument got %s", x, a.Lit)
+			}
+		}
+		res, err := c.Translate(tag, key, args)
+		if err != nil {
+			return nil, err
+		}
+		return &exp.Atom{Lit: lit.Str(res)}, nil
+	}
+}
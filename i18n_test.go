@@ -0,0 +1,67 @@
+package layla
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/mb0/xelf/lit"
+)
+
+func TestCatalogs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en.json": {Data: []byte(`{
+			"packed": "Packed on: ",
+			"vendor": "Made by {name}",
+			"pieces": {"zero": "no pieces", "one": "{n} piece", "other": "{n} pieces"}
+		}`)},
+		"de.json": {Data: []byte(`{
+			"packed": "Verpackt am: ",
+			"pieces": {"one": "{n} Stück", "other": "{n} Stück"}
+		}`)},
+		"fr.json": {Data: []byte(`{
+			"pieces": {"one": "{n} pièce", "other": "{n} pièces"}
+		}`)},
+		"de-AT.json": {Data: []byte(`{"packed": "Abgepackt am: "}`)},
+	}
+	c, err := LoadCatalogs(fsys, "en")
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	rec := func(kv ...lit.Keyed) lit.Keyer { return lit.RecFromKeyed(kv) }
+	tests := []struct {
+		tag, key string
+		args     lit.Keyer
+		want     string
+	}{
+		{"de", "packed", nil, "Verpackt am: "},
+		{"de-AT", "packed", nil, "Abgepackt am: "},
+		{"de-CH", "packed", nil, "Verpackt am: "},
+		{"nl", "packed", nil, "Packed on: "},
+		{"de", "vendor", rec(lit.Keyed{"name", lit.Str("Firma")}), "Made by Firma"},
+		{"en", "pieces", rec(lit.Keyed{"n", lit.Int(0)}), "no pieces"},
+		{"en", "pieces", rec(lit.Keyed{"n", lit.Int(1)}), "1 piece"},
+		{"en", "pieces", rec(lit.Keyed{"n", lit.Int(1200)}), "1,200 pieces"},
+		{"fr", "pieces", rec(lit.Keyed{"n", lit.Num(1.5)}), "1,5 pièce"},
+		{"fr", "pieces", rec(lit.Keyed{"n", lit.Int(2)}), "2 pièces"},
+		{"de", "pieces", rec(lit.Keyed{"n", lit.Int(0)}), "0 Stück"},
+	}
+	for _, test := range tests {
+		got, err := c.Translate(test.tag, test.key, test.args)
+		if err != nil {
+			t.Errorf("%s %s error: %v", test.tag, test.key, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s %s want %q got %q", test.tag, test.key, test.want, got)
+		}
+	}
+	if _, err := c.Translate("de", "missing", nil); err == nil {
+		t.Errorf("want error for missing key")
+	}
+	if _, err := c.Translate("en", "vendor", nil); err == nil {
+		t.Errorf("want error for missing placeholder")
+	}
+	if _, err := LoadCatalogs(fsys, "it"); err == nil {
+		t.Errorf("want error for missing default catalog")
+	}
+}
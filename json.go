@@ -0,0 +1,159 @@
+package layla
+
+import (
+	"encoding/json"
+
+	"github.com/mb0/layla/font"
+	"github.com/mb0/layla/mark"
+	"github.com/mb0/xelf/cor"
+)
+
+// JSONVersion is the version of the json interchange format written by Doc.
+const JSONVersion = 1
+
+// Doc is the json interchange document for node trees and display lists.
+//
+// The tree holds a node tree, the draw list holds the display nodes returned by Page ready to
+// be rendered. Nodes are encoded as objects with the tags of the node fields, omitting zero
+// values. In addition to the template tags, nodes include the layout results:
+//
+//	calc     the calculated box {x y w h} of laid out tree nodes
+//	loc      the unrotated local box {x y w h deg} of nodes rotated during layout
+//	src      the source position {file line col}
+//	font     the font additionally includes the markup style tag and the measured height
+//
+// Child nodes read with a font equal to the parent font share the parent font. Readers must
+// ignore unknown fields, the version is only incremented for incompatible changes.
+type Doc struct {
+	Version int     `json:"version"`
+	Tree    *Node   `json:"-"`
+	Draw    []*Node `json:"-"`
+}
+
+type jsonDoc struct {
+	Version int         `json:"version"`
+	Tree    *jsonNode   `json:"tree,omitempty"`
+	Draw    []*jsonNode `json:"draw,omitempty"`
+}
+
+type plainNode Node
+
+type jsonNode struct {
+	plainNode
+	Sub    *Dim        `json:"sub,omitempty"`
+	Border *Border     `json:"border,omitempty"`
+	Font   *jsonFont   `json:"font,omitempty"`
+	List   []*jsonNode `json:"list,omitempty"`
+	Calc   *Box        `json:"calc,omitempty"`
+	Loc    *jsonLoc    `json:"loc,omitempty"`
+	Src    *jsonSrc    `json:"src,omitempty"`
+}
+
+type jsonFont struct {
+	Font
+	Tag    mark.Tag `json:"tag,omitempty"`
+	Height font.Pt  `json:"height,omitempty"`
+}
+
+type jsonLoc struct {
+	Box
+	Deg int `json:"deg"`
+}
+
+type jsonSrc struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Col  int    `json:"col,omitempty"`
+}
+
+// MarshalJSON returns the json interchange document.
+func (d Doc) MarshalJSON() ([]byte, error) {
+	res := jsonDoc{Version: JSONVersion}
+	if d.Tree != nil {
+		res.Tree = toJSON(d.Tree)
+	}
+	for _, n := range d.Draw {
+		res.Draw = append(res.Draw, toJSON(n))
+	}
+	return json.Marshal(res)
+}
+
+// UnmarshalJSON reads a json interchange document or returns an error for newer versions.
+func (d *Doc) UnmarshalJSON(b []byte) error {
+	var doc jsonDoc
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return err
+	}
+	if doc.Version > JSONVersion {
+		return cor.Errorf("unsupported layla json version %d", doc.Version)
+	}
+	*d = Doc{Version: doc.Version}
+	if doc.Tree != nil {
+		d.Tree = fromJSON(doc.Tree, nil)
+	}
+	for _, n := range doc.Draw {
+		d.Draw = append(d.Draw, fromJSON(n, nil))
+	}
+	return nil
+}
+
+func toJSON(n *Node) *jsonNode {
+	j := &jsonNode{plainNode: plainNode(*n)}
+	if n.Sub != (Dim{}) {
+		sub := n.Sub
+		j.Sub = &sub
+	}
+	if n.Border != (Border{}) {
+		br := n.Border
+		j.Border = &br
+	}
+	if n.Font != nil {
+		j.Font = &jsonFont{Font: *n.Font, Tag: n.Font.Style, Height: n.Font.Height}
+	}
+	if n.Calc != (Box{}) {
+		c := n.Calc
+		j.Calc = &c
+	}
+	if n.loc != nil {
+		j.Loc = &jsonLoc{n.loc.Box, n.loc.Deg}
+	}
+	if n.Src != (Src{}) {
+		j.Src = &jsonSrc{n.Src.File, n.Src.Line, n.Src.Col}
+	}
+	for _, e := range n.List {
+		j.List = append(j.List, toJSON(e))
+	}
+	return j
+}
+
+func fromJSON(j *jsonNode, p *Node) *Node {
+	n := Node(j.plainNode)
+	n.Font, n.List = nil, nil
+	if j.Sub != nil {
+		n.Sub = *j.Sub
+	}
+	if j.Border != nil {
+		n.Border = *j.Border
+	}
+	if f := j.Font; f != nil {
+		n.Font = &f.Font
+		n.Font.Style, n.Font.Height = f.Tag, f.Height
+		if p != nil && p.Font != nil && *p.Font == *n.Font {
+			n.Font = p.Font
+		}
+	}
+	if j.Calc != nil {
+		n.Calc = *j.Calc
+	}
+	if j.Loc != nil {
+		n.loc = &local{j.Loc.Box, j.Loc.Deg}
+	}
+	if j.Src != nil {
+		n.Src = Src{j.Src.File, j.Src.Line, j.Src.Col}
+	}
+	for _, e := range j.List {
+		n.List = append(n.List, fromJSON(e, &n))
+	}
+	return &n
+}
@@ -0,0 +1,64 @@
+package layla
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/mark"
+)
+
+func TestDocJSON(t *testing.T) {
+	f := &Font{Name: "regular", Size: 7, Line: 41, Style: mark.B, Height: 640}
+	tree := &Node{Kind: "stage", Box: Box{Dim: Dim{W: 360, H: 360}}, Font: f,
+		Calc: Box{Dim: Dim{W: 360, H: 360}}, Src: Src{File: "a.layla", Line: 1, Col: 1},
+		List: []*Node{
+			{Kind: "text", Font: f, Data: "Hello", Calc: Box{Pos{8, 8}, Dim{79, 41}}},
+			{Kind: "vbox", NodeLayout: NodeLayout{Rot: 90}, Calc: Box{Dim: Dim{W: 41, H: 79}},
+				loc: &local{Box{Dim: Dim{W: 79, H: 41}}, 90}},
+		},
+	}
+	draw := []*Node{
+		{Kind: "text", Box: Box{Pos{8, 8}, Dim{79, 41}}, Font: f, Data: "Hello"},
+		{Kind: "page"},
+	}
+	raw, err := json.Marshal(Doc{Tree: tree, Draw: draw})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	for _, want := range []string{
+		`{"version":1,"tree":{"kind":"stage","w":360,"h":360,"font":{"name":"regular",`,
+		`{"kind":"page"}]}`,
+		`"font":{"name":"regular","size":7,"line":41,"tag":1,"height":640}`,
+		`"calc":{"x":8,"y":8,"w":79,"h":41}`,
+		`"loc":{"w":79,"h":41,"deg":90}`,
+		`"src":{"file":"a.layla","line":1,"col":1}`,
+		`"draw":[{"kind":"text","x":8,"y":8,"w":79,"h":41,`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("want json to contain %s\n%s", want, raw)
+		}
+	}
+	var doc Doc
+	err = json.Unmarshal(raw, &doc)
+	if err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !reflect.DeepEqual(doc.Tree, tree) {
+		t.Errorf("tree round trip mismatch:\n%+v\n%+v", doc.Tree, tree)
+	}
+	if doc.Tree.List[0].Font != doc.Tree.Font {
+		t.Errorf("want shared parent font")
+	}
+	if !reflect.DeepEqual(doc.Draw, draw) {
+		t.Errorf("draw round trip mismatch:\n%+v\n%+v", doc.Draw, draw)
+	}
+	raw2, _ := json.Marshal(doc)
+	if string(raw2) != string(raw) {
+		t.Errorf("unstable json:\n%s\n%s", raw, raw2)
+	}
+	if err := json.Unmarshal([]byte(`{"version":2}`), &doc); err == nil {
+		t.Errorf("want error for newer version")
+	}
+}
@@ -74,7 +74,9 @@ type Font struct {
 	Height font.Pt  `json:"-"`
 }
 
-// NodeLayout holds all layout related node data
+// NodeLayout holds all layout related node data.
+// Rot is the clockwise rotation in degrees. The rotation of the root node turns the whole stage.
+// Other nodes can be rotated in 90 degree steps, text and code nodes also by arbitrary angles.
 type NodeLayout struct {
 	Mar   *Off    `json:"mar,omitempty"`
 	Pad   *Off    `json:"pad,omitempty"`
@@ -118,11 +120,37 @@ func (b Border) Default(w float64) Border {
 	return b
 }
 
+// Table holds the column widths of table and columns nodes, zero widths share the remaining
+// width, and whether the first table row is a head repeated on each page.
 type Table struct {
 	Cols []float64 `json:"cols,omitempty"`
 	Head bool      `json:"head,omitempty"`
 }
 
+// Paging holds the page break controls of nodes in page documents.
+// Keep keeps the node together on one page and Next keeps it on the page with the start of its
+// next sibling, if they fit on a new page. Break is either 'before' or 'after' and starts a new
+// page before or after the node. Widows and orphans are the minimum number of lines of a text
+// split across pages at the top of the next and at the bottom of the first page.
+// On selects the pages of extra, header and footer nodes as 'first', 'odd' or 'even' and Restart
+// restarts the page numbers of a section. Anchor names the node as target of ref fields, or the
+// footnote as target of note fields, and Toc marks the node as table of contents entry of level
+// one to four. Span lets a child of a columns node cross all columns and Balance balances the
+// column heights of a columns node on the last page and before spanning nodes.
+type Paging struct {
+	Keep    bool   `json:"keep,omitempty"`
+	Next    bool   `json:"next,omitempty"`
+	Break   string `json:"break,omitempty"`
+	Widows  int    `json:"widows,omitempty"`
+	Orphans int    `json:"orphans,omitempty"`
+	On      string `json:"on,omitempty"`
+	Restart bool   `json:"restart,omitempty"`
+	Anchor  string `json:"anchor,omitempty"`
+	Toc     int    `json:"toc,omitempty"`
+	Span    bool   `json:"span,omitempty"`
+	Balance bool   `json:"balance,omitempty"`
+}
+
 // Node is a part of the display tree represents all display elements.
 type Node struct {
 	Kind string `json:"kind"`
@@ -130,9 +158,29 @@ type Node struct {
 	NodeLayout
 	Font   *Font   `json:"font,omitempty"`
 	Border Border  `json:"border,omitempty"`
+	Color  *Color  `json:"color,omitempty"`
 	List   []*Node `json:"list,omitempty"`
 	Table
+	Paging
 	Code *Code  `json:"code,omitempty"`
 	Data string `json:"data,omitempty"`
+	// Style holds space separated names of styles applied to the node before layout.
+	Style string `json:"style,omitempty"`
+	// Link is an url target for the node area. Child nodes inherit the link of their parent.
+	Link string `json:"link,omitempty"`
 	Calc Box    `json:"-"`
+	// Src is the template source position set by the resolver.
+	Src Src `json:"-"`
+	// Part is the box part of debug display nodes, see DebugColors.
+	Part string `json:"-"`
+	loc  *local
+	// alignSet is set by the resolver for nodes with an explicit align attribute. It tells
+	// explicit from inherited alignments of the same value when styles are applied.
+	alignSet bool
+	// notes holds the footnotes detached from the tree of a page root before layout.
+	notes []*note
+	// fld holds the field source of text and markup nodes with fields.
+	fld *field
+	// toc holds the collected entries of toc nodes.
+	toc []*tocEntry
 }
@@ -21,6 +21,10 @@ func TestLayla(t *testing.T) {
 		{`(stage w:360 h:360 (rect))`, `{kind:'rect' w:360 h:360}`},
 		{`(rect w:360 h:360 (text 'Hello'))`, `{kind:'rect' w:360 h:360}` +
 			`{kind:'text' w:79 h:41 font:{line:41} data:'Hello'}`},
+		{`(stage w:360 h:360 (style 'big' font.line:60) (text style:'big' 'Hello'))`,
+			`{kind:'text' w:79 h:60 font:{line:60} data:'Hello'}`},
+		{`(box w:360 h:360 link:'http://a.b' (text 'Hello'))`,
+			`{kind:'text' w:79 h:41 font:{line:41} data:'Hello' link:'http://a.b'}`},
 		{`(box w:360 h:360 (text 'Mr. A BC'))`,
 			`{kind:'text' w:136 h:41 font:{line:41} data:'Mr. A BC'}`},
 		{`(stage w:360 h:360 (rect h:100))`, `{kind:'rect' w:360 h:100}`},
@@ -65,6 +69,19 @@ func TestLayla(t *testing.T) {
 		{`(vbox w:300 h:300 list:(list (text 'Hello') (text 'World')))`, "" +
 			`{kind:'text' w:300 h:41 font:{line:41} data:'Hello'}` +
 			`{kind:'text' y:41 w:300 h:41 font:{line:41} data:'World'}`},
+		{`(stage w:360 h:360 (text rot:90 h:200 'Hello'))`,
+			`{kind:'text' w:41 h:200 rot:90 font:{line:41} data:'Hello'}`},
+		{`(stage w:360 h:360 (text x:20 y:30 rot:180 'Hello'))`,
+			`{kind:'text' x:20 y:30 w:79 h:41 rot:180 font:{line:41} data:'Hello'}`},
+		{`(stage w:360 h:360 (text rot:45 'Hello'))`,
+			`{kind:'text' w:79 h:41 rot:45 font:{line:41} data:'Hello'}`},
+		{`(stage w:360 h:360 (hbox (text w:100 'Left')` +
+			`(vbox rot:270 h:300 (text 'Best before') (rect h:10))` +
+			`(text 'Right')))`, "" +
+			`{kind:'text' w:100 h:41 font:{line:41} data:'Left'}` +
+			`{kind:'text' x:100 w:41 h:300 rot:270 font:{line:41} data:'Best before'}` +
+			`{kind:'rect' x:141 w:10 h:300 rot:270}` +
+			`{kind:'text' x:151 w:79 h:41 font:{line:41} data:'Right'}`},
 		{`(page w:200 h:41 (text 'Page3'))`, "" +
 			`{kind:'text' w:97 h:41 font:{line:41} data:'Page3'}`},
 		{`(page w:200 h:41 (vbox (text 'Page1') (text 'Page2') (text 'Page3')))`, "" +
@@ -88,7 +105,7 @@ func TestLayla(t *testing.T) {
 			t.Errorf("exec %s error: %+v", test.raw, err)
 			continue
 		}
-		lay := &Layouter{man, 'i', FakeBoldStyler}
+		lay := &Layouter{Manager: man, Spacer: 'i', Styler: FakeBoldStyler}
 		draw, err := lay.LayoutAndPage(n)
 		if err != nil {
 			t.Errorf("layout err: %v\n%v", err, n)
@@ -108,6 +125,27 @@ func TestLayla(t *testing.T) {
 	}
 }
 
+// pageSource executes and lays out the template source raw with font manager m and returns the
+// root node and the display nodes formatted with f, without empty results. F can be nil if only
+// the error is checked.
+func pageSource(m *font.Manager, raw string, f func(*Node) string) (*Node, []string, error) {
+	n, err := ExecuteString(Env, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	draw, err := NewLayouter(m).LayoutAndPage(n)
+	if err != nil || f == nil {
+		return n, nil, err
+	}
+	var res []string
+	for _, d := range draw {
+		if s := f(d); s != "" {
+			res = append(res, s)
+		}
+	}
+	return n, res, nil
+}
+
 func TestMeasure(t *testing.T) {
 	man := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
 	if err := man.Err(); err != nil {
@@ -133,7 +171,7 @@ func TestMeasure(t *testing.T) {
 			t.Errorf("exec %s error: %+v", test.raw, err)
 			continue
 		}
-		lay := Layouter{man, 'i', FakeBoldStyler}
+		lay := Layouter{Manager: man, Spacer: 'i', Styler: FakeBoldStyler}
 		b, err := lay.layout(n, Box{Dim: Dim{100, 0}}, nil)
 		if err != nil {
 			t.Errorf("measure %s error: %+v", test.raw, err)
@@ -31,8 +31,12 @@ func FakeBoldStyler(m *font.Manager, f Font, t mark.Tag) (*font.Face, error) {
 }
 
 func LayoutAndPage(m *font.Manager, n *Node) ([]*Node, error) {
-	l := &Layouter{m, ' ', ZeroStyler}
-	return l.LayoutAndPage(n)
+	return NewLayouter(m).LayoutAndPage(n)
+}
+
+// NewLayouter returns a new layouter for font manager m with the default spacer and styler.
+func NewLayouter(m *font.Manager) *Layouter {
+	return &Layouter{Manager: m, Spacer: ' ', Styler: ZeroStyler}
 }
 
 // Layouter implements the layout routine and holds required context
@@ -40,32 +44,82 @@ type Layouter struct {
 	*font.Manager
 	Spacer rune
 	Styler
+	// AddCheck appends missing check digits to barcode data instead of returning an error.
+	AddCheck bool
+	// Debug adds display nodes for the boxes of container nodes to the display list.
+	Debug bool
+	// Styles are shared style sheets of style and styles nodes used for all layouts.
+	Styles []*Node
 }
 
 // Layout measures and sets the nodes dimensions and position or returns an error
 func (l *Layouter) Layout(n *Node) error {
-	_, err := l.layout(n, n.Box, nil)
-	return err
+	err := ApplyStyles(n, l.Styles...)
+	if err != nil {
+		return err
+	}
+	if n.notes == nil {
+		n.notes, err = footnotes(n)
+		if err != nil {
+			return err
+		}
+	}
+	err = prepFields(n)
+	if err != nil {
+		return err
+	}
+	prepToc(n)
+	_, err = l.layout(n, n.Box, nil)
+	if err != nil {
+		return err
+	}
+	return l.layoutNotes(n)
 }
 
-// LayoutAndPage layouts the node and returns a slice of nodes to draw or an error
+// LayoutAndPage layouts the node and returns a slice of nodes to draw or an error.
+// Nodes with fields and toc nodes are laid out again with the resolved values until those are
+// stable.
 func (l *Layouter) LayoutAndPage(n *Node) ([]*Node, error) {
-	_, err := l.layout(n, n.Box, nil)
-	if err != nil {
-		return nil, err
+	for pass := 1; ; pass++ {
+		err := l.Layout(n)
+		if err != nil {
+			return nil, err
+		}
+		p, res, err := paginate(n, l.Debug)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := resolveFields(n, p)
+		if err != nil {
+			return nil, err
+		}
+		if resolveToc(n, p) {
+			changed = true
+		}
+		if !changed || pass == maxPasses {
+			return res, nil
+		}
 	}
-	return Page(n)
 }
 
 // layout sets the calculated absolute box inside the available bounds a and returns
 // the required area including margins.
 // The passed in dimension can be unbounded vertically by setting h <= 0
+// Errors are returned as node errors with the source position and path of the failing node.
 func (l *Layouter) layout(n *Node, a Box, stack []*Node) (_ Box, err error) {
+	defer func() { err = nodeErr(n, stack, err) }()
 	if a.W <= 0 {
 		return n.Calc, cor.Errorf("layout always needs available width")
 	}
+	rot, err := nodeRot(n, stack)
+	if err != nil {
+		return n.Calc, err
+	}
 	m := getMargin(n)
 	ab := m.Inset(a)
+	if rot == 90 || rot == 270 {
+		return l.rotLayout(n, ab, m, rot, stack)
+	}
 	nb := Box{Pos: ab.Pos, Dim: n.Dim}
 	nb.W = clampFill(ab.W, nb.W)
 	if nb.W < ab.W {
@@ -78,6 +132,20 @@ func (l *Layouter) layout(n *Node, a Box, stack []*Node) (_ Box, err error) {
 	}
 	nb.H = clamp(ab.H, nb.H)
 	n.Calc = nb
+	err = l.layoutKind(n, ab, stack)
+	if err != nil {
+		return Box{}, err
+	}
+	n.loc = nil
+	if rot == 180 {
+		n.loc = &local{n.Calc, rot}
+	}
+	return m.Outset(n.Calc), nil
+}
+
+// layoutKind measures the node n, with a calculated box already set, based on its kind.
+func (l *Layouter) layoutKind(n *Node, ab Box, stack []*Node) (err error) {
+	nb := n.Calc
 	switch n.Kind {
 	case "text":
 		err = l.lineLayout(n, stack)
@@ -86,12 +154,14 @@ func (l *Layouter) layout(n *Node, a Box, stack []*Node) (_ Box, err error) {
 	case "line":
 		n.Calc.W = n.W
 	case "qrcode":
+		err = checkCode(n, stack, l.AddCheck)
 		if nb.H == 0 || nb.W < nb.H {
 			n.Calc.H = nb.W
 		} else if nb.H > 0 && nb.W > nb.H {
 			n.Calc.W = nb.H
 		}
 	case "barcode":
+		err = checkCode(n, stack, l.AddCheck)
 	case "box", "rect", "ellipse":
 		n.Calc.H = clampFill(ab.H, nb.H)
 		err = l.freeLayout(n, stack)
@@ -102,18 +172,73 @@ func (l *Layouter) layout(n *Node, a Box, stack []*Node) (_ Box, err error) {
 	case "page":
 		n.Calc.H = 0
 		err = l.freeLayout(n, stack)
-	case "vbox":
+	case "vbox", "footnote":
 		err = l.vboxLayout(n, stack)
+	case "section":
+		err = l.sectionLayout(n, stack)
+	case "toc":
+		err = l.tocLayout(n, stack)
+	case "columns":
+		err = l.columnsLayout(n, stack)
 	case "hbox":
 		err = l.hboxLayout(n, stack)
 	case "table":
 		err = l.tableLayout(n, stack)
 	}
+	return err
+}
+
+// rotLayout lays out the node n rotated by 90 or 270 degrees inside the available box ab.
+// The content is measured in the unrotated local space, where the available width is the
+// available height. The calculated box is set to the rotated area and the local box is kept
+// for the pager to transform the content.
+func (l *Layouter) rotLayout(n *Node, ab Box, m Off, rot int, stack []*Node) (Box, error) {
+	n.Dim = RotDim(n.Dim, rot)
+	defer func() { n.Dim = RotDim(n.Dim, rot) }()
+	la := Box{Pos: ab.Pos, Dim: RotDim(ab.Dim, rot)}
+	nb := Box{Pos: la.Pos, Dim: n.Dim}
+	nb.W = clampFill(la.W, nb.W)
+	if nb.W <= 0 {
+		return n.Calc, cor.Errorf("rotated %s needs a height", n.Kind)
+	}
+	nb.H = clamp(la.H, nb.H)
+	n.Calc = nb
+	err := l.layoutKind(n, la, stack)
 	if err != nil {
 		return Box{}, err
 	}
-	return m.Outset(n.Calc), nil
+	lb := n.Calc
+	rb := Box{Pos: lb.Pos, Dim: RotDim(lb.Dim, rot)}
+	if rb.W < ab.W {
+		switch n.Align {
+		case AlignRight:
+			rb.X += math.Floor(ab.W - rb.W)
+		case AlignCenter:
+			rb.X += math.Ceil((ab.W - rb.W) / 2)
+		}
+	}
+	n.Calc = rb
+	n.loc = &local{lb, rot}
+	return m.Outset(rb), nil
 }
+
+// nodeRot returns the rotation of node n in 90 degree steps. It returns zero for the root node,
+// which is rotated by the renderer, and for data nodes with an arbitrary angle, which are
+// rotated around their center by the renderer.
+func nodeRot(n *Node, stack []*Node) (int, error) {
+	if n.Rot == 0 || len(stack) == 0 {
+		return 0, nil
+	}
+	if n.Rot%90 != 0 {
+		switch n.Kind {
+		case "text", "barcode", "qrcode":
+			return 0, nil
+		}
+		return 0, cor.Errorf("%s can only be rotated in 90 degree steps", n.Kind)
+	}
+	return NormRot(n.Rot)
+}
+
 func (l *Layouter) freeLayout(n *Node, stack []*Node) error {
 	stack = append(stack, n)
 	a := n.Pad.Inset(n.Calc)
@@ -0,0 +1,232 @@
+package layla
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Diag is a lint diagnostic with the source position and node path of the offending template node.
+type Diag struct {
+	Src  Src
+	Path string
+	Msg  string
+}
+
+func (d Diag) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Src, d.Path, d.Msg)
+}
+
+// attrKinds maps attributes to the node kinds they have an effect on. Attributes not in this map
+// work on all node kinds.
+var attrKinds = map[string][]string{
+	"sub":     {"vbox", "hbox", "footnote", "section"},
+	"sub.w":   {"hbox"},
+	"sub.h":   {"vbox", "footnote", "section"},
+	"gap":     {"vbox", "hbox", "table", "footnote", "section", "toc", "columns"},
+	"cols":    {"table", "columns"},
+	"head":    {"table"},
+	"widows":  {"text"},
+	"orphans": {"text"},
+	"on":      {"extra", "header", "footer"},
+	"restart": {"section"},
+	"balance": {"columns"},
+	"code":    {"qrcode", "barcode"},
+	"border":  {"rect", "ellipse", "line", "text"},
+	"color":   {"rect", "ellipse", "line", "text"},
+	"font": {"stage", "rect", "ellipse", "box", "vbox", "hbox", "table", "page", "extra",
+		"cover", "header", "footer", "footnote", "section", "toc", "columns", "text", "markup", "barcode"},
+}
+
+// Lint parses the layla source src of the named file and returns diagnostics for unknown node
+// attributes, attributes without effect on the node kind, font names missing from fonts and
+// literal coordinates outside the stage. Font names are only checked if fonts is not empty.
+//
+// Coordinates are checked by summing up the literal x and y offsets of a node and its ancestors
+// and comparing them to the literal dimensions of the root stage.
+func Lint(src []byte, file string, fonts []string) ([]Diag, error) {
+	ts, err := ParseTrees(src, file)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{}
+	if len(fonts) > 0 {
+		l.fonts = make(map[string]bool, len(fonts))
+		for _, f := range fonts {
+			l.fonts[f] = true
+		}
+	}
+	for _, t := range ts {
+		for _, n := range nodeKids(t) {
+			l.node(n, "", 0, 0, nil)
+		}
+	}
+	return l.res, nil
+}
+
+type linter struct {
+	fonts map[string]bool
+	stage *Tree
+	sw    float64
+	sh    float64
+	res   []Diag
+}
+
+func (l *linter) add(t *Tree, path, format string, args ...interface{}) {
+	l.res = append(l.res, Diag{t.Src, path, fmt.Sprintf(format, args...)})
+}
+
+// nodeKids returns the node calls in t and its non-node calls, or t itself if it is a node call.
+func nodeKids(t *Tree) []*Tree {
+	if t.isNode() {
+		return []*Tree{t}
+	}
+	var res []*Tree
+	if t.Tok == '(' {
+		for _, e := range t.Seq {
+			res = append(res, nodeKids(e)...)
+		}
+	}
+	return res
+}
+
+func (l *linter) node(t *Tree, path string, x, y float64, kids []*Tree) {
+	kind := t.head()
+	if path != "" {
+		path += "/"
+	}
+	path += kind
+	var idx, cnt int
+	for _, k := range kids {
+		if k.head() == kind {
+			cnt++
+			if k == t {
+				idx = cnt
+			}
+		}
+	}
+	if cnt > 1 {
+		path += fmt.Sprintf("[%d]", idx)
+	}
+	tags := make(map[string]*Tree)
+	var list []*Tree
+	for _, a := range t.Seq[1:] {
+		if a.Tok != ':' {
+			list = append(list, nodeKids(a)...)
+			continue
+		}
+		tags[a.Raw] = a
+		if l.attr(a, kind, path) && a.Raw == "list" && len(a.Seq) > 0 {
+			for _, e := range a.Seq[0].Seq {
+				list = append(list, nodeKids(e)...)
+			}
+		}
+	}
+	if l.stage == nil && kind == "stage" {
+		l.stage = t
+		l.sw, l.sh = tagNum(tags["w"]), tagNum(tags["h"])
+	} else if l.stage != nil {
+		x += tagNum(tags["x"])
+		y += tagNum(tags["y"])
+		l.coord(path, "x", x, l.sw, tags)
+		l.coord(path, "y", y, l.sh, tags)
+	}
+	for _, e := range list {
+		l.node(e, path, x, y, list)
+	}
+}
+
+// attr checks the tag a of a node of kind and returns whether it is a known attribute.
+func (l *linter) attr(a *Tree, kind, path string) bool {
+	base, field := a.Raw, ""
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base, field = base[:i], base[i+1:]
+	}
+	fields, ok := attrFields[base]
+	if !ok || field != "" && !hasStr(fields, field) {
+		l.add(a, path, "unknown attribute %s", a.Raw)
+		return false
+	}
+	if kind != "style" && kind != "styles" {
+		ks, ok := attrKinds[a.Raw]
+		if !ok {
+			ks, ok = attrKinds[base]
+		}
+		if ok && !hasStr(ks, kind) {
+			l.add(a, path, "attribute %s has no effect on %s", a.Raw, kind)
+		}
+	}
+	if base == "font" && l.fonts != nil && len(a.Seq) > 0 {
+		if name, ok := fontName(a.Raw, a.Seq[0]); ok && !l.fonts[name] {
+			l.add(a, path, "unknown font %q", name)
+		}
+	}
+	return true
+}
+
+// coord checks the summed literal offset v of the named axis against the stage dimension max.
+func (l *linter) coord(path, axis string, v, max float64, tags map[string]*Tree) {
+	tag := tags[axis]
+	if tag == nil {
+		return
+	}
+	if v < 0 {
+		l.add(tag, path, "%s %g is outside the stage", axis, v)
+		return
+	}
+	if max <= 0 {
+		return
+	}
+	dim := map[string]string{"x": "w", "y": "h"}[axis]
+	if v >= max {
+		l.add(tag, path, "%s %g is outside the stage %s %g", axis, v, dim, max)
+	} else if d := tagNum(tags[dim]); v+d > max {
+		l.add(tag, path, "%s %g and %s %g exceed the stage %s %g", axis, v, dim, d, dim, max)
+	}
+}
+
+// fontName returns the literal font name of the font tag name with value v.
+func fontName(name string, v *Tree) (string, bool) {
+	switch {
+	case name == "font.name":
+	case name != "font":
+		return "", false
+	case v.Tok == '[' && len(v.Seq) > 0:
+		v = v.Seq[0]
+	case v.Tok == '{':
+		var found *Tree
+		for _, e := range v.Seq {
+			if e.Tok == ':' && e.Raw == "name" && len(e.Seq) > 0 {
+				found = e.Seq[0]
+			}
+		}
+		if found == nil {
+			return "", false
+		}
+		v = found
+	default:
+		return "", false
+	}
+	if v.Tok != 0 || len(v.Raw) < 3 || !strings.ContainsAny(v.Raw[:1], "'\"`") {
+		return "", false
+	}
+	return v.Raw[1 : len(v.Raw)-1], true
+}
+
+// tagNum returns the literal number value of tag or zero.
+func tagNum(tag *Tree) float64 {
+	if tag == nil || len(tag.Seq) == 0 || tag.Seq[0].Tok != 0 {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(tag.Seq[0].Raw, 64)
+	return v
+}
+
+func hasStr(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
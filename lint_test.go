@@ -0,0 +1,63 @@
+package layla
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	raw := `(stage w:200 h:100 font:['regular' 8]
+	(vbox cols:[10 20] sub.w:4 sub.h:8 foo:1
+		(text sub.h:4 font.name:'fancy' 'a')
+		(text x:-2 'b')
+	)
+	(box x:150 w:60
+		(rect x:60 y:120 border.q:1)
+	)
+	(table cols:[40 40] font:{name:'bold'} (text 'c'))
+)`
+	got, err := Lint([]byte(raw), "l.layla", []string{"regular", "bold"})
+	if err != nil {
+		t.Fatalf("lint error: %v", err)
+	}
+	var res []string
+	for _, d := range got {
+		res = append(res, d.String())
+	}
+	want := []string{
+		"l.layla:2:8: stage/vbox: attribute cols has no effect on vbox",
+		"l.layla:2:21: stage/vbox: attribute sub.w has no effect on vbox",
+		"l.layla:2:37: stage/vbox: unknown attribute foo",
+		"l.layla:3:9: stage/vbox/text[1]: attribute sub.h has no effect on text",
+		"l.layla:3:17: stage/vbox/text[1]: unknown font \"fancy\"",
+		"l.layla:4:9: stage/vbox/text[2]: x -2 is outside the stage",
+		"l.layla:6:7: stage/box: x 150 and w 60 exceed the stage w 200",
+		"l.layla:7:20: stage/box/rect: unknown attribute border.q",
+		"l.layla:7:9: stage/box/rect: x 210 is outside the stage w 200",
+		"l.layla:7:14: stage/box/rect: y 120 is outside the stage h 100",
+	}
+	if strings.Join(res, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want diagnostics:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(res, "\n"))
+	}
+}
+
+func TestNodeAttrs(t *testing.T) {
+	want := "x y w h mar pad rot align gap sub font border color list cols head keep next break " +
+		"widows orphans on restart anchor toc span balance code data style link"
+	if got := strings.Join(attrOrder, " "); got != want {
+		t.Errorf("want attr order %s got %s", want, got)
+	}
+	fields := map[string]string{
+		"mar": "l t r b", "sub": "w h", "font": "name size line", "border": "w l t r b",
+		"color": "r g b", "code": "name human wide", "x": "", "list": "", "anchor": "",
+	}
+	for a, want := range fields {
+		fs, ok := attrFields[a]
+		if got := strings.Join(fs, " "); !ok || got != want {
+			t.Errorf("want %s fields %q got %q", a, want, got)
+		}
+	}
+	if _, ok := attrFields["kind"]; ok {
+		t.Errorf("kind must not be an attribute")
+	}
+}
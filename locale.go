@@ -0,0 +1,196 @@
+package layla
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mb0/xelf/exp"
+	"github.com/mb0/xelf/std"
+	"github.com/mb0/xelf/utl"
+)
+
+// Locale holds the conventions used to format dates, numbers and currencies.
+type Locale struct {
+	Tag string
+	// Decimal and Group are the decimal and thousands separators.
+	Decimal string
+	Group   string
+	// Date and DateLong are go time layouts. The english month names in DateLong are replaced
+	// by the localized Months.
+	Date     string
+	DateLong string
+	Months   [12]string
+	// CurFirst indicates that the currency symbol is written before the amount, and CurSpace
+	// that symbol and amount are separated by a space.
+	CurFirst bool
+	CurSpace bool
+}
+
+// Locales holds the built-in locales by language tag. Numbers, units and currencies use
+// non-breaking spaces, so they are not wrapped in text layouts.
+var Locales = map[string]*Locale{
+	"de": {Tag: "de", Decimal: ",", Group: ".",
+		Date: "02.01.2006", DateLong: "2. January 2006",
+		Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+			"Juli", "August", "September", "Oktober", "November", "Dezember"},
+		CurSpace: true,
+	},
+	"fr": {Tag: "fr", Decimal: ",", Group: "\u00a0",
+		Date: "02/01/2006", DateLong: "2 January 2006",
+		Months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin",
+			"juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		CurSpace: true,
+	},
+	"nl": {Tag: "nl", Decimal: ",", Group: ".",
+		Date: "02-01-2006", DateLong: "2 January 2006",
+		Months: [12]string{"januari", "februari", "maart", "april", "mei", "juni",
+			"juli", "augustus", "september", "oktober", "november", "december"},
+		CurFirst: true, CurSpace: true,
+	},
+	"en": {Tag: "en", Decimal: ".", Group: ",",
+		Date: "02/01/2006", DateLong: "2 January 2006",
+		Months: [12]string{"January", "February", "March", "April", "May", "June",
+			"July", "August", "September", "October", "November", "December"},
+		CurFirst: true,
+	},
+}
+
+// FindLocale returns the locale for a language tag like 'de' or 'de-AT' or nil.
+func FindLocale(tag string) *Locale {
+	tag = strings.ToLower(strings.Replace(tag, "_", "-", -1))
+	for tag != "" {
+		if l := Locales[tag]; l != nil {
+			return l
+		}
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return nil
+}
+
+// LocaleEnv returns the default layla environment with formatting functions for the locale
+// with tag. Unknown tags use the english locale.
+func LocaleEnv(tag string) exp.Builtin {
+	loc := FindLocale(tag)
+	if loc == nil {
+		loc = Locales["en"]
+	}
+	return exp.Builtin{
+		NodeLookup,
+		FmtLib(loc).Lookup(),
+		utl.StrLib.Lookup(),
+		utl.TimeLib.Lookup(),
+		std.Core, std.Decl,
+	}
+}
+
+// FmtLib returns a library of formatting functions using the locale loc:
+//
+//	(fmt_date $now)             05.10.2019
+//	(fmt_date_long $now)        5. Oktober 2019
+//	(fmt_num 1234.5 2)          1.234,50
+//	(fmt_money 1234.5 'EUR')    1.234,50 €
+//	(fmt_unit 0.25 'kg')        0,25 kg
+func FmtLib(loc *Locale) utl.Lib {
+	return utl.Lib{
+		"fmt_date":      utl.MustReflectFunc("fmt_date", loc.FormatDate, "t"),
+		"fmt_date_long": utl.MustReflectFunc("fmt_date_long", loc.FormatDateLong, "t"),
+		"fmt_num":       utl.MustReflectFunc("fmt_num", loc.FormatNum, "v", "prec"),
+		"fmt_money":     utl.MustReflectFunc("fmt_money", loc.FormatMoney, "v", "cur"),
+		"fmt_unit":      utl.MustReflectFunc("fmt_unit", loc.FormatUnit, "v", "unit"),
+	}
+}
+
+// Plural returns the plural category one or other for the count n.
+func (l *Locale) Plural(n float64) string {
+	if n == 1 || l.Tag == "fr" && n >= 0 && n < 2 {
+		return "one"
+	}
+	return "other"
+}
+
+// FormatDate returns the short numeric date of t.
+func (l *Locale) FormatDate(t time.Time) string { return t.Format(l.Date) }
+
+// FormatDateLong returns the date of t with the localized month name.
+func (l *Locale) FormatDateLong(t time.Time) string {
+	s := t.Format(l.DateLong)
+	return strings.Replace(s, t.Month().String(), l.Months[t.Month()-1], 1)
+}
+
+// FormatNum returns v with prec decimal places and grouped thousands.
+func (l *Locale) FormatNum(v float64, prec int) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', prec, 64)
+	var frac string
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, frac = s[:i], s[i+1:]
+	}
+	var b strings.Builder
+	if v < 0 && strings.Trim(s+frac, "0") != "" {
+		b.WriteByte('-')
+	}
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// currencies maps iso currency codes to symbols and decimal places.
+var currencies = map[string]struct {
+	Sym  string
+	Prec int
+}{
+	"EUR": {"€", 2},
+	"USD": {"$", 2},
+	"GBP": {"£", 2},
+	"CHF": {"CHF", 2},
+	"JPY": {"¥", 0},
+}
+
+// FormatMoney returns the amount v in the currency with iso code cur. Unknown currencies use
+// the code as symbol with two decimal places.
+func (l *Locale) FormatMoney(v float64, cur string) string {
+	cur = strings.ToUpper(cur)
+	sym, prec := cur, 2
+	if c, ok := currencies[cur]; ok {
+		sym, prec = c.Sym, c.Prec
+	}
+	num := l.FormatNum(v, prec)
+	sep := ""
+	if l.CurSpace || len(sym) > 1 && sym == cur {
+		sep = "\u00a0"
+	}
+	if l.CurFirst {
+		if strings.HasPrefix(num, "-") {
+			return "-" + sym + sep + num[1:]
+		}
+		return sym + sep + num
+	}
+	return num + sep + sym
+}
+
+// FormatUnit returns the quantity v with up to three decimal places and the unit symbol
+// like kg, g, l or ml.
+func (l *Locale) FormatUnit(v float64, unit string) string {
+	num := l.FormatNum(v, 3)
+	if strings.Contains(num, l.Decimal) {
+		num = strings.TrimRight(num, "0")
+		num = strings.TrimSuffix(num, l.Decimal)
+	}
+	if unit == "" {
+		return num
+	}
+	return num + "\u00a0" + unit
+}
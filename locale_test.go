@@ -0,0 +1,47 @@
+package layla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocale(t *testing.T) {
+	now := time.Date(2019, time.March, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{"de-AT", []string{"05.03.2019", "5. März 2019", "-1.234.567,89",
+			"1.234,50\u00a0€", "12,00\u00a0CHF", "0,25\u00a0kg", "1.500\u00a0ml"}},
+		{"fr", []string{"05/03/2019", "5 mars 2019", "-1\u00a0234\u00a0567,89",
+			"1\u00a0234,50\u00a0€", "12,00\u00a0CHF", "0,25\u00a0kg", "1\u00a0500\u00a0ml"}},
+		{"nl_NL", []string{"05-03-2019", "5 maart 2019", "-1.234.567,89",
+			"€\u00a01.234,50", "CHF\u00a012,00", "0,25\u00a0kg", "1.500\u00a0ml"}},
+		{"en", []string{"05/03/2019", "5 March 2019", "-1,234,567.89",
+			"€1,234.50", "CHF\u00a012.00", "0.25\u00a0kg", "1,500\u00a0ml"}},
+	}
+	for _, test := range tests {
+		l := FindLocale(test.tag)
+		if l == nil {
+			t.Errorf("locale %s not found", test.tag)
+			continue
+		}
+		got := []string{
+			l.FormatDate(now),
+			l.FormatDateLong(now),
+			l.FormatNum(-1234567.891, 2),
+			l.FormatMoney(1234.5, "eur"),
+			l.FormatMoney(12, "CHF"),
+			l.FormatUnit(0.250, "kg"),
+			l.FormatUnit(1500, "ml"),
+		}
+		for i, w := range test.want {
+			if got[i] != w {
+				t.Errorf("%s format %d want %q got %q", test.tag, i, w, got[i])
+			}
+		}
+	}
+	if l := FindLocale("it"); l != nil {
+		t.Errorf("want no locale for it got %s", l.Tag)
+	}
+}
@@ -1,60 +1,125 @@
 package layla
 
 import (
-	"fmt"
 	"math"
 	"strings"
+
+	"github.com/mb0/xelf/cor"
 )
 
+// Page returns the display list of the laid out node n. Fields in body texts keep the values of
+// the last layout, use LayoutAndPage to resolve them.
 func Page(n *Node) ([]*Node, error) {
-	p := newPager(n)
-	err := p.collect(n)
+	_, res, err := paginate(n, false)
+	return res, err
+}
+
+// PageDebug returns the display list of the laid out node n like Page, but also includes display
+// nodes of kind debug for the boxes of container nodes. Debug nodes of containers that do not fit
+// the remaining page are clipped.
+func PageDebug(n *Node) ([]*Node, error) {
+	_, res, err := paginate(n, true)
+	return res, err
+}
+
+// paginate returns the pager and display list of the laid out node n.
+func paginate(n *Node, debug bool) (*pager, []*Node, error) {
+	p := &pager{Node: n}
+	if debug {
+		p.paths = debugPaths(n)
+	}
+	err := p.init()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	err = p.collect(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	for len(p.pending) > 0 {
+		p.newPage(p.list[len(p.list)-1].Org)
+	}
+	for i, x := range p.list {
+		// pages after a restarted section continue the numbering of the enclosing section
+		if c := i - x.sec.start + 1; c > p.runs[x.sec.start] {
+			p.runs[x.sec.start] = c
+		}
 	}
 	var res []*Node
-	total := fmt.Sprint(len(p.list))
 	for i, x := range p.list {
 		if i > 0 {
 			res = append(res, &Node{Kind: "page"})
 		}
-		x.page = fmt.Sprint(i + 1)
-		x.total = total
-		if p.Extra != nil {
-			res = x.collect(p.Extra, res, 0)
+		v := p.vals(i)
+		x.vals = &v
+		if x.extra != nil {
+			res = x.collect(x.extra, res, 0)
 		}
-		top := p.Cover
-		if i > 0 {
-			top = p.Header
+		if x.top != nil {
+			res = x.collect(x.top, res, 0)
 		}
-		if top != nil {
-			res = x.collect(top, res, 0)
+		if x.foot != nil {
+			offy := x.Y + x.H + x.nh
+			res = x.collect(x.foot, res, offy)
 		}
-		if p.Footer != nil {
-			offy := x.Y + x.H
-			res = x.collect(p.Footer, res, offy)
+		if x.err != nil {
+			return nil, nil, x.err
 		}
 		res = append(res, x.res...)
+		y := x.Y + x.H
+		for _, part := range x.notes {
+			for _, d := range part.ds {
+				d.Y += y
+				res = append(res, d)
+			}
+			y += part.h
+		}
 	}
-	return res, nil
+	return p, res, nil
+}
+
+// vals returns the field values for the page with index i.
+func (p *pager) vals(i int) pageVals {
+	x := p.list[i]
+	return pageVals{i + 1, len(p.list), i - x.sec.start + 1, p.runs[x.sec.start], p.anchors,
+		p.nums}
 }
 
 type xpage struct {
 	Org float64
 	Box
 	res   []*Node
-	page  string
-	total string
+	paths map[*Node]string
+	// vals holds the field values used to draw the extra, top and foot nodes.
+	vals *pageVals
+	err  error
+	// idx is the page index and sec the section with the selected extra, top and foot nodes.
+	idx   int
+	sec   *section
+	extra *Node
+	top   *Node
+	foot  *Node
+	// notes holds the footnote parts placed below the body and nh their total height.
+	notes []notePart
+	nh    float64
 }
 
 func collectCopy(n *Node) *Node {
-	d := &Node{Kind: n.Kind, Box: n.Calc, Border: n.Border}
+	d := &Node{Kind: n.Kind, Box: n.Calc, Border: n.Border, Color: n.Color, Link: n.Link,
+		Src: n.Src}
+	if n.loc != nil {
+		d.Box = n.loc.Box
+	} else if n.Rot%90 != 0 {
+		d.Rot = n.Rot
+	}
 	switch n.Kind {
 	case "text":
 		d.Font = n.Font
 		d.Data = n.Data
 		d.Align = n.Align
 		d.Mar = n.Mar
+		d.Widows = n.Widows
+		d.Orphans = n.Orphans
 	case "qrcode", "barcode":
 		d.Code = n.Code
 		d.Data = n.Data
@@ -63,12 +128,30 @@ func collectCopy(n *Node) *Node {
 }
 
 func (x *xpage) collect(n *Node, res []*Node, offy float64) []*Node {
+	if n.loc == nil {
+		return x.collectNode(n, res, offy)
+	}
+	start := len(res)
+	res = x.collectNode(n, res, 0)
+	for _, d := range res[start:] {
+		n.loc.place(d, n.Calc.Pos)
+		d.Y += offy
+	}
+	return res
+}
+
+func (x *xpage) collectNode(n *Node, res []*Node, offy float64) []*Node {
 	var d *Node
 	switch n.Kind {
 	case "text":
 		d = collectCopy(n)
-		d.Data = strings.ReplaceAll(d.Data, "µP", x.page)
-		d.Data = strings.ReplaceAll(d.Data, "µT", x.total)
+		if x.vals != nil && hasFields(d.Data, true) {
+			data, err := expandFields(d.Data, x.vals)
+			if err != nil && x.err == nil {
+				x.err = nodeErr(n, nil, err)
+			}
+			d.Data = data
+		}
 	case "line", "qrcode", "barcode":
 		d = collectCopy(n)
 	case "rect", "ellipse":
@@ -77,7 +160,13 @@ func (x *xpage) collect(n *Node, res []*Node, offy float64) []*Node {
 		res = append(res, d)
 		fallthrough
 	case "stage", "box", "vbox", "hbox", "table", "page",
-		"extra", "cover", "header", "footer", "markup":
+		"extra", "cover", "header", "footer", "markup", "footnote", "section", "toc", "columns":
+		if x.paths != nil {
+			for _, d := range debugNodes(n, x.paths[n]) {
+				d.Y += offy
+				res = append(res, d)
+			}
+		}
 		for _, e := range n.List {
 			res = x.collect(e, res, offy)
 		}
@@ -89,52 +178,95 @@ func (x *xpage) collect(n *Node, res []*Node, offy float64) []*Node {
 
 type pager struct {
 	*Node
-	Extra  *Node
-	Cover  *Node
-	Header *Node
-	Footer *Node
-	THead  []*Node
-	list   []*xpage
+	Cover *Node
+	THead []*Node
+	// sec is the current section, starting with the root section of the page node.
+	sec   *section
+	list  []*xpage
+	paths map[*Node]string
+	// refs maps text and markup nodes to the footnotes they reference and nums holds the
+	// footnote numbers by id.
+	refs map[*Node]*noteRefs
+	nums map[string]int
+	// pending holds footnote parts carried over to the next new page.
+	pending []notePart
+	// after is the document offset after a node with a page break after it or zero.
+	after float64
+	// trail holds the page of each added display node.
+	trail []*xpage
+	// at maps text, markup, anchor and toc entry nodes to the index of the page they start on.
+	at      map[*Node]int
+	anchors map[string]int
+	// runs maps the first page index of a page numbering run to its page count.
+	runs map[int]int
+	// flow is set for pagers of columns nodes, where each new page is a column.
+	flow *flow
 }
 
-func newPager(n *Node) *pager {
-	p := &pager{Node: n}
+func (p *pager) init() error {
+	n := p.Node
+	p.at = make(map[*Node]int)
+	p.anchors = make(map[string]int)
+	p.runs = make(map[int]int)
 	for _, e := range n.List {
-		switch e.Kind {
-		case "extra":
-			p.Extra = e
-		case "cover":
+		if e.Kind == "cover" {
 			p.Cover = e
-		case "header":
-			p.Header = e
-		case "footer":
-			p.Footer = e
 		}
 	}
+	var err error
+	p.sec, err = newSection(n, nil)
+	if err != nil {
+		return err
+	}
+	p.nums = noteNums(n.notes)
+	for _, nt := range n.notes {
+		if p.refs == nil {
+			p.refs = make(map[*Node]*noteRefs)
+		}
+		nt.done = false
+		x := xpage{paths: p.paths}
+		nt.ds = x.collect(nt.Node, nil, 0)
+	}
 	p.newPage(0)
-	return p
+	return nil
 }
 
-func (p *pager) newPage(org float64) *xpage {
-	b := p.Pad.Inset(Box{Dim: p.Dim})
-	top := p.Cover
-	if len(p.list) > 0 {
-		top = p.Header
+// body returns the body box of the new page with index i, the selected extra, top and foot nodes
+// and the height of the repeated table head.
+func (p *pager) body(i int) (b Box, extra, top, foot *Node, mh float64) {
+	b = p.Pad.Inset(Box{Dim: p.Dim})
+	extra = p.sec.pick(p.sec.extra, i)
+	top = p.sec.pick(p.sec.head, i)
+	if i == 0 && p.Cover != nil {
+		top = p.Cover
 	}
+	foot = p.sec.pick(p.sec.foot, i)
 	if top != nil {
 		h := top.Calc.H
 		b.Y += h
 		b.H -= b.Y
 	}
-	if p.Footer != nil {
-		b.H -= p.Footer.Calc.H
+	if foot != nil {
+		b.H -= foot.Calc.H
 	}
-	x := &xpage{Org: org, Box: b}
-	var mh float64
 	for _, th := range p.THead {
 		if th.Calc.H > mh {
 			mh = th.Calc.H
 		}
+	}
+	return b, extra, top, foot, mh
+}
+
+func (p *pager) newPage(org float64) *xpage {
+	if p.flow != nil {
+		x := p.flow.next(org)
+		p.list = append(p.list, x)
+		return x
+	}
+	b, extra, top, foot, mh := p.body(len(p.list))
+	x := &xpage{Org: org, Box: b, paths: p.paths, idx: len(p.list), sec: p.sec,
+		extra: extra, top: top, foot: foot}
+	for _, th := range p.THead {
 		x.res = x.collect(th, x.res, x.Y-th.Calc.Y)
 	}
 	if mh > 0 {
@@ -142,15 +274,52 @@ func (p *pager) newPage(org float64) *xpage {
 		x.H -= mh
 	}
 	p.list = append(p.list, x)
+	pending := p.pending
+	p.pending = nil
+	for _, part := range pending {
+		p.placeNote(x, 0, part.ds, part.h)
+	}
 	return x
 }
 
 func (p *pager) collect(n *Node) error {
+	if n.Anchor != "" {
+		if _, ok := p.anchors[n.Anchor]; ok {
+			return nodeErr(n, nil, cor.Errorf("duplicate anchor %q", n.Anchor))
+		}
+	}
+	if n.Kind == "text" || n.Kind == "markup" || n.Anchor != "" || n.Toc > 0 {
+		start := len(p.trail)
+		defer func() {
+			if len(p.trail) > start {
+				p.at[n] = p.trail[start].idx
+			}
+			if n.Anchor != "" {
+				p.anchors[n.Anchor] = p.at[n] + 1
+			}
+		}()
+	}
+	if n.loc != nil {
+		// rotated nodes are transformed as a whole
+		x := xpage{paths: p.paths}
+		for _, d := range x.collect(n, nil, 0) {
+			p.draw(d, nil, nil)
+		}
+		return nil
+	}
+	switch n.Kind {
+	case "rect", "ellipse", "table", "stage", "box", "vbox", "hbox", "page", "markup", "toc":
+		if p.paths != nil {
+			for _, d := range debugNodes(n, p.paths[n]) {
+				p.draw(d, nil, nil)
+			}
+		}
+	}
 	switch n.Kind {
 	case "text", "line", "qrcode", "barcode":
-		p.draw(collectCopy(n), n.Mar)
+		p.draw(collectCopy(n), n.Mar, p.noteRefs(n))
 	case "rect", "ellipse":
-		p.draw(collectCopy(n), n.Mar)
+		p.draw(collectCopy(n), n.Mar, nil)
 		return p.collectAll(n.List)
 	case "table":
 		hh := n.Head && len(p.THead) == 0
@@ -166,25 +335,201 @@ func (p *pager) collect(n *Node) error {
 			p.THead = nil
 		}
 		return err
-	case "stage", "box", "vbox", "hbox", "page", "markup":
+	case "markup":
+		if refs := p.noteRefs(n); refs != nil {
+			for _, e := range n.List {
+				p.refs[e] = refs
+			}
+		}
+		return p.collectAll(n.List)
+	case "stage", "box", "vbox", "hbox", "page", "toc":
 		return p.collectAll(n.List)
+	case "section":
+		return p.collectSection(n)
+	case "columns":
+		return p.collectColumns(n)
 	case "extra", "cover", "header", "footer":
 	}
 	return nil
 }
 
 func (p *pager) collectAll(ns []*Node) (err error) {
-	for _, e := range ns {
-		err := p.collect(e)
+	for i, e := range ns {
+		if p.Kind == "page" {
+			err = p.paging(e, ns[i+1:], i > 0 && ns[i-1].Next)
+			if err != nil {
+				return err
+			}
+		}
+		err = p.collect(e)
 		if err != nil {
 			return err
 		}
+		if e.Break == "after" {
+			p.after = e.Calc.Y + e.Calc.H
+		}
 	}
 	return nil
 
 }
 
-func (p *pager) draw(n *Node, m *Off) {
+// paging starts a new page before node n if requested by a preceding break, the break of n, or
+// if n with keep, or the group of n and its following siblings with next, does not fit the
+// remaining page but a new one. Grouped is true if n was already handled as part of a group.
+func (p *pager) paging(n *Node, next []*Node, grouped bool) error {
+	switch n.Break {
+	case "", "before", "after":
+	default:
+		return nodeErr(n, nil, cor.Errorf("unknown page break %q", n.Break))
+	}
+	y := n.Calc.Y
+	if n.Break == "before" || p.after > 0 && y >= p.after {
+		p.after = 0
+		p.breakAt(y)
+		return nil
+	}
+	if grouped {
+		return nil
+	}
+	h := n.Calc.H
+	if n.Next {
+		e := n
+		for _, f := range next {
+			if !e.Next {
+				break
+			}
+			h = f.Calc.Y - y + leadH(f)
+			e = f
+		}
+	} else if !n.Keep {
+		return nil
+	}
+	if p.fits(y, h) {
+		return nil
+	}
+	if h <= p.pageH() {
+		p.breakAt(y)
+	}
+	return nil
+}
+
+// pageH returns the height available on a new page or column.
+func (p *pager) pageH() float64 {
+	if p.flow != nil {
+		return p.flow.p.pageH()
+	}
+	b, _, _, _, mh := p.body(len(p.list))
+	return b.H - mh
+}
+
+// leadH returns the height of the leading part of node n, that must be on the same page as
+// a preceding node that keeps with next. That is the whole node if it is kept together, the
+// first lines of a text or the lead of the first child of a container.
+func leadH(n *Node) float64 {
+	switch {
+	case n.Keep:
+	case n.Kind == "text" && n.Font != nil && n.Font.Line > 0:
+		lines := math.Max(1, float64(n.Orphans))
+		return math.Min(n.Calc.H, lines*n.Font.Line)
+	case len(n.List) > 0 && n.Kind != "markup":
+		f := n.List[0]
+		return f.Calc.Y - n.Calc.Y + leadH(f)
+	}
+	return n.Calc.H
+}
+
+// fits returns whether the content at document offset y with height h fits on its page.
+func (p *pager) fits(y, h float64) bool {
+	x := p.pageAt(y)
+	return x == nil || y-x.Org+h <= x.H
+}
+
+// breakAt starts a new page at document offset y unless the last page has no content yet.
+func (p *pager) breakAt(y float64) {
+	x := p.pageAt(y)
+	if x == p.list[len(p.list)-1] && y > x.Org && x.used() > 0 {
+		p.newPage(y)
+	}
+}
+
+// pageAt returns the page the document offset y starts on or nil.
+func (p *pager) pageAt(y float64) *xpage {
+	for i := len(p.list) - 1; i >= 0; i-- {
+		if x := p.list[i]; x.Org <= y {
+			return x
+		}
+	}
+	return nil
+}
+
+// noteRefs returns the footnote references of the text or markup node n or nil.
+func (p *pager) noteRefs(n *Node) *noteRefs {
+	if p.refs == nil {
+		return nil
+	}
+	if r := p.refs[n]; r != nil {
+		return r
+	}
+	if n.fld == nil || len(n.fld.notes) == 0 {
+		return nil
+	}
+	r := &noteRefs{list: n.fld.notes}
+	p.refs[n] = r
+	return r
+}
+
+// addNode adds the display node d to page x and places the footnotes referenced in the part of
+// the text of refs, that is displayed by d.
+func (p *pager) addNode(x *xpage, d *Node, refs *noteRefs) {
+	x.res = append(x.res, d)
+	p.trail = append(p.trail, x)
+	if refs == nil {
+		return
+	}
+	start := refs.seen
+	refs.seen += nonSpace(d.Data)
+	for _, r := range refs.list {
+		if r.off < start || r.off >= refs.seen {
+			continue
+		}
+		for _, nt := range p.notes {
+			if nt.done || nt.Mark != r.mark {
+				continue
+			}
+			nt.done = true
+			if p.flow != nil {
+				p.flow.placeNote(x, d, nt)
+			} else {
+				p.placeNote(x, d.Y+d.H-x.Y, nt.ds, nt.H)
+			}
+		}
+	}
+}
+
+// lineControl returns the line count lc of text with total remaining lines to place on the
+// current page, adjusted to leave at least widows lines for the next page and, if it is the first
+// page of the text, to place at least orphans lines or none.
+func lineControl(lc, total int, first bool, widows, orphans int) int {
+	if lc >= total {
+		return lc
+	}
+	res := lc
+	if widows > 0 && total-res < widows {
+		res = total - widows
+	}
+	if first && res < orphans {
+		res = 0
+	}
+	if res < 1 && !first {
+		return lc
+	}
+	if res < 0 {
+		return 0
+	}
+	return res
+}
+
+func (p *pager) draw(n *Node, m *Off, refs *noteRefs) {
 	if p.Kind != "page" {
 		xp := p.list[0]
 		xp.res = append(xp.res, n)
@@ -200,13 +545,20 @@ func (p *pager) draw(n *Node, m *Off) {
 		// simple case fits into the remaining space
 		if y+n.H <= x.H {
 			n.Y = x.Y + y
+			p.addNode(x, n, refs)
+			return
+		}
+		// debug boxes are clipped to the remaining space of the starting page
+		if n.Kind == "debug" && y < x.H {
+			n.Y = x.Y + y
+			n.H = x.H - y
 			x.res = append(x.res, n)
 			return
 		}
 		switch n.Kind {
 		case "text":
 			txt := strings.Split(n.Data, "\n")
-			if len(txt) <= 1 {
+			if len(txt) <= 1 || n.Rot != 0 {
 				break
 			}
 			lh := n.Font.Line
@@ -220,13 +572,14 @@ func (p *pager) draw(n *Node, m *Off) {
 				if lc > len(txt) {
 					lc = len(txt)
 				}
+				lc = lineControl(lc, len(txt), j == 0, n.Widows, n.Orphans)
 				if lc > 0 {
 					nn := *n
 					nn.Y = x.Y + y
 					nn.H = math.Ceil(lh * float64(lc))
 					hh += nn.H
 					nn.Data = strings.Join(txt[:lc], "\n")
-					x.res = append(x.res, &nn)
+					p.addNode(x, &nn, refs)
 					txt = txt[lc:]
 				}
 				if len(txt) == 0 {
@@ -256,7 +609,7 @@ func (p *pager) draw(n *Node, m *Off) {
 		if m != nil {
 			n.Y += m.T
 		}
-		x.res = append(x.res, n)
+		p.addNode(x, n, refs)
 		return
 	}
 }
@@ -0,0 +1,81 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestPaging(t *testing.T) {
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`(page w:200 h:100 (vbox
+			(rect h:70)
+			(vbox keep:true (rect h:20) (rect h:20))
+			(rect h:10 next:true)
+			(rect h:55)
+			(rect h:10 break:'after')
+			(rect h:5)
+			(rect h:5 break:'before')))`, []string{
+			"rect 0 70",
+			"page 0 0",
+			"rect 0 20", "rect 20 20",
+			"page 0 0",
+			"rect 0 10", "rect 10 55", "rect 65 10",
+			"page 0 0",
+			"rect 0 5",
+			"page 0 0",
+			"rect 0 5",
+		}},
+		{`(page w:200 h:30 font.line:10 (text widows:2 'a\nb\nc\nd'))`, []string{
+			"text 0 20",
+			"page 0 0",
+			"text 0 20",
+		}},
+	}
+	for _, test := range tests {
+		_, got, err := pageSource(m, test.raw, func(d *Node) string {
+			return fmt.Sprintf("%s %g %g", d.Kind, d.Y, d.H)
+		})
+		if err != nil {
+			t.Errorf("for %s error: %v", test.raw, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("for %s\nwant display list:\n%s\ngot:\n%s", test.raw,
+				strings.Join(test.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+	_, _, err := pageSource(m, `(page w:200 h:100 (vbox (rect h:10 break:'never')))`, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown page break "never"`) {
+		t.Errorf("want break error got %v", err)
+	}
+}
+
+func TestLineControl(t *testing.T) {
+	tests := []struct {
+		lc, total       int
+		first           bool
+		widows, orphans int
+		want            int
+	}{
+		{3, 5, true, 0, 0, 3},
+		{6, 5, true, 2, 2, 6},
+		{4, 5, true, 2, 0, 3},
+		{1, 5, true, 0, 2, 0},
+		{2, 3, true, 2, 2, 0},
+		{4, 5, false, 2, 2, 3},
+		{1, 2, false, 2, 0, 1},
+	}
+	for _, test := range tests {
+		got := lineControl(test.lc, test.total, test.first, test.widows, test.orphans)
+		if got != test.want {
+			t.Errorf("line control %+v got %d", test, got)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/mb0/xelf/cor"
+)
+
+// Level is a PDF/A conformance level.
+type Level string
+
+const (
+	// PDFA2B is PDF/A-2 level B for the visual appearance of archived documents.
+	PDFA2B Level = "2B"
+	// PDFA3B is PDF/A-3 level B, that additionally allows associated files of any format.
+	PDFA3B Level = "3B"
+)
+
+// File is an associated file embedded in a PDF/A-3 document, like a Factur-X invoice xml.
+type File struct {
+	Name string
+	Desc string
+	// Mime is the media type of the file content, it defaults to application/octet-stream.
+	Mime string
+	// Rel is the relationship to the document: Source, Data, Alternative, Supplement or
+	// Unspecified, which is the default.
+	Rel  string
+	Data []byte
+	Mod  time.Time
+}
+
+// Archive writes pdf documents conforming to a PDF/A level.
+//
+// Gofpdf has no support for PDF/A, so the archive rewrites the document output. It adds an sRGB
+// output intent, xmp metadata matching the document information, a document id, the print flag
+// of link annotations and the associated files. Documents using encryption, javascript, files
+// attached by gofpdf or fonts that are not embedded are rejected.
+type Archive struct {
+	Level Level
+	Meta
+	Files []File
+	// XMP holds additional rdf description elements for the xmp metadata, for example the
+	// extension schema required by the Factur-X standard.
+	XMP string
+}
+
+// Output writes the pdf document d to w or returns an error.
+func (a *Archive) Output(w io.Writer, d *Doc) error {
+	var part int
+	switch a.Level {
+	case PDFA2B:
+		part = 2
+	case PDFA3B:
+		part = 3
+	default:
+		return cor.Errorf("unknown pdf/a level %q", a.Level)
+	}
+	if part < 3 && len(a.Files) > 0 {
+		return cor.Errorf("pdf/a-%s does not allow associated files", a.Level)
+	}
+	created := a.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	created = created.UTC().Truncate(time.Second)
+	a.Meta.Set(d)
+	d.SetCreator("layla", false)
+	d.SetProducer("gofpdf", false)
+	d.SetCreationDate(created)
+	d.SetModificationDate(created)
+	var raw bytes.Buffer
+	err := d.Output(&raw)
+	if err != nil {
+		return err
+	}
+	x, err := readXref(raw.Bytes())
+	if err != nil {
+		return err
+	}
+	err = x.check()
+	if err != nil {
+		return err
+	}
+	x.fix(created)
+	x.add(streamObj("/Type /Metadata /Subtype /XML", []byte(a.xmp(part, created))))
+	meta := x.n - 1
+	x.add(streamObj("/N 3", srgbProfile))
+	x.add([]byte(fmt.Sprintf("<< /Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier "+
+		"(sRGB IEC61966-2.1) /Info (sRGB IEC61966-2.1) /DestOutputProfile %d 0 R >>", x.n-1)))
+	intent := x.n - 1
+	files := make([]int, 0, len(a.Files))
+	for _, f := range a.Files {
+		mod := f.Mod
+		if mod.IsZero() {
+			mod = created
+		}
+		mime := f.Mime
+		if mime == "" {
+			mime = "application/octet-stream"
+		}
+		x.add(streamObj(fmt.Sprintf("/Type /EmbeddedFile /Subtype %s "+
+			"/Params << /ModDate (%s) /Size %d >>",
+			pdfName(mime), pdfDate(mod), len(f.Data)), f.Data))
+		rel := f.Rel
+		if rel == "" {
+			rel = "Unspecified"
+		}
+		x.add([]byte(fmt.Sprintf("<< /Type /Filespec /F %s /UF %s /Desc %s "+
+			"/AFRelationship %s /EF << /F %d 0 R /UF %[5]d 0 R >> >>",
+			pdfStr(f.Name), utf16Str(f.Name), utf16Str(f.Desc), pdfName(rel), x.n-1)))
+		files = append(files, x.n-1)
+	}
+	var cat strings.Builder
+	cat.WriteString(x.catalog)
+	if len(files) > 0 {
+		idx := make([]int, len(files))
+		for i := range idx {
+			idx[i] = i
+		}
+		// name tree keys must be sorted
+		sort.SliceStable(idx, func(i, j int) bool { return a.Files[idx[i]].Name < a.Files[idx[j]].Name })
+		cat.WriteString("/Names << /EmbeddedFiles << /Names [")
+		for _, i := range idx {
+			fmt.Fprintf(&cat, " %s %d 0 R", pdfStr(a.Files[i].Name), files[i])
+		}
+		cat.WriteString(" ] >> >>\n/AF [")
+		for _, n := range files {
+			fmt.Fprintf(&cat, " %d 0 R", n)
+		}
+		cat.WriteString(" ]\n")
+	}
+	fmt.Fprintf(&cat, "/Metadata %d 0 R\n/OutputIntents [%d 0 R]\n", meta, intent)
+	x.set(x.root, []byte("<<\n"+cat.String()+">>"))
+	_, err = w.Write(x.bytes())
+	return err
+}
+
+func (a *Archive) xmp(part int, created time.Time) string {
+	var b strings.Builder
+	date := created.Format("2006-01-02T15:04:05Z")
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+		"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+		"<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	fmt.Fprintf(&b, "<rdf:Description rdf:about=\"\" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\">\n"+
+		"<pdfaid:part>%d</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n"+
+		"</rdf:Description>\n", part)
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n" +
+		"<dc:format>application/pdf</dc:format>\n")
+	if a.Title != "" {
+		fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li>"+
+			"</rdf:Alt></dc:title>\n", xmlEsc(a.Title))
+	}
+	if a.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n",
+			xmlEsc(a.Author))
+	}
+	if a.Subject != "" {
+		fmt.Fprintf(&b, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li>"+
+			"</rdf:Alt></dc:description>\n", xmlEsc(a.Subject))
+	}
+	b.WriteString("</rdf:Description>\n" +
+		"<rdf:Description rdf:about=\"\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n" +
+		"<pdf:Producer>gofpdf</pdf:Producer>\n")
+	if len(a.Keywords) > 0 {
+		fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>\n", xmlEsc(strings.Join(a.Keywords, " ")))
+	}
+	fmt.Fprintf(&b, "</rdf:Description>\n"+
+		"<rdf:Description rdf:about=\"\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\">\n"+
+		"<xmp:CreatorTool>layla</xmp:CreatorTool>\n"+
+		"<xmp:CreateDate>%s</xmp:CreateDate>\n<xmp:ModifyDate>%[1]s</xmp:ModifyDate>\n"+
+		"</rdf:Description>\n", date)
+	b.WriteString(a.XMP)
+	b.WriteString("</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.String()
+}
+
+// xref holds the objects of a pdf document written by gofpdf.
+type xref struct {
+	head    []byte
+	objs    [][]byte
+	n       int
+	root    int
+	info    int
+	catalog string
+	trailer string
+}
+
+func readXref(b []byte) (*xref, error) {
+	xi := bytes.LastIndex(b, []byte("\nxref\n"))
+	ti := bytes.LastIndex(b, []byte("\ntrailer\n"))
+	if xi < 0 || ti < xi {
+		return nil, cor.Errorf("pdf without xref table")
+	}
+	lines := strings.Split(string(b[xi+6:ti]), "\n")
+	var first, n int
+	_, err := fmt.Sscanf(lines[0], "%d %d", &first, &n)
+	if err != nil || first != 0 || len(lines) < n+1 {
+		return nil, cor.Errorf("unexpected xref table %q", lines[0])
+	}
+	offs := make([]int, n)
+	for i := 1; i < n; i++ {
+		f := strings.Fields(lines[i+1])
+		if len(f) != 3 || f[2] != "n" {
+			return nil, cor.Errorf("unexpected xref entry %q", lines[i+1])
+		}
+		offs[i], err = strconv.Atoi(f[0])
+		if err != nil {
+			return nil, err
+		}
+		if offs[i] <= 0 || offs[i] >= xi {
+			return nil, cor.Errorf("unexpected xref offset for object %d", i)
+		}
+	}
+	x := &xref{objs: make([][]byte, n), n: n, trailer: string(b[ti+1:])}
+	x.root = trailerRef(x.trailer, "/Root")
+	x.info = trailerRef(x.trailer, "/Info")
+	if x.root <= 0 || x.root >= n || x.info <= 0 || x.info >= n {
+		return nil, cor.Errorf("pdf trailer without root and info")
+	}
+	order := make([]int, 0, n-1)
+	for i := 1; i < n; i++ {
+		order = append(order, i)
+	}
+	sort.Slice(order, func(i, j int) bool { return offs[order[i]] < offs[order[j]] })
+	x.head = b[:offs[order[0]]]
+	for i, o := range order {
+		end := xi + 1
+		if i+1 < len(order) {
+			end = offs[order[i+1]]
+		}
+		obj := b[offs[o]:end]
+		// strip the object header and footer
+		head := []byte(fmt.Sprintf("%d 0 obj\n", o))
+		e := bytes.LastIndex(obj, []byte("endobj"))
+		if !bytes.HasPrefix(obj, head) || e < len(head) {
+			return nil, cor.Errorf("unexpected pdf object %d", o)
+		}
+		x.objs[o] = bytes.TrimRight(obj[len(head):e], "\n")
+	}
+	cat := string(x.objs[x.root])
+	if i := strings.Index(cat, "/Names <<"); i >= 0 {
+		cat = cat[:i]
+	} else {
+		cat = strings.TrimSuffix(cat, ">>")
+	}
+	x.catalog = strings.TrimPrefix(cat, "<<\n")
+	return x, nil
+}
+
+func trailerRef(t, key string) int {
+	i := strings.Index(t, key+" ")
+	if i < 0 {
+		return 0
+	}
+	var n int
+	fmt.Sscanf(t[i+len(key)+1:], "%d", &n)
+	return n
+}
+
+// check returns an error if the document uses features not allowed by PDF/A.
+func (x *xref) check() error {
+	if strings.Contains(x.trailer, "/Encrypt") {
+		return cor.Errorf("pdf/a does not allow encryption")
+	}
+	if strings.Contains(string(x.objs[x.root]), "/JavaScript") {
+		return cor.Errorf("pdf/a does not allow javascript")
+	}
+	for i, obj := range x.objs {
+		// stream data is not checked, but the stream dictionary is
+		obj, _ = splitStream(obj)
+		if bytes.Contains(obj, []byte("/Type /EmbeddedFile")) {
+			return cor.Errorf("pdf/a requires associated files to be added to the archive")
+		}
+		if bytes.Contains(obj, []byte("/Subtype /Type1")) &&
+			!bytes.Contains(obj, []byte("/FontDescriptor")) {
+			return cor.Errorf("pdf/a requires embedded fonts, object %d is a core font", i)
+		}
+	}
+	return nil
+}
+
+// fix sets the print flag of link annotations and adds the utc time zone to the document dates.
+func (x *xref) fix(created time.Time) {
+	x.head = append([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), x.head[bytes.IndexByte(x.head, '\n')+1:]...)
+	for i, obj := range x.objs {
+		dict, data := splitStream(obj)
+		if !bytes.Contains(dict, []byte("/Subtype /Link ")) {
+			continue
+		}
+		dict = bytes.Replace(dict, []byte("/Subtype /Link "), []byte("/Subtype /Link /F 4 "), -1)
+		x.objs[i] = append(dict, data...)
+	}
+	date := "D:" + created.Format("20060102150405")
+	x.objs[x.info] = bytes.Replace(x.objs[x.info], []byte(date+")"), []byte(pdfDate(created)+")"), -1)
+}
+
+func (x *xref) add(obj []byte) {
+	x.objs = append(x.objs, obj)
+	x.n++
+}
+
+func (x *xref) set(n int, obj []byte) { x.objs[n] = obj }
+
+func (x *xref) bytes() []byte {
+	var b bytes.Buffer
+	b.Write(x.head)
+	offs := make([]int, len(x.objs))
+	for i := 1; i < len(x.objs); i++ {
+		offs[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i)
+		b.Write(x.objs[i])
+		b.WriteString("\nendobj\n")
+	}
+	id := md5.Sum(b.Bytes())
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(x.objs))
+	for _, o := range offs[1:] {
+		fmt.Fprintf(&b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&b, "trailer\n<<\n/Size %d\n/Root %d 0 R\n/Info %d 0 R\n/ID [<%x> <%[4]x>]\n>>\n",
+		len(x.objs), x.root, x.info, id)
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+// splitStream returns the object obj without stream data and the stream data starting with the
+// stream keyword, if obj is a stream.
+func splitStream(obj []byte) (dict, data []byte) {
+	if i := bytes.Index(obj, []byte("\nstream\n")); i >= 0 {
+		return obj[:i:i], obj[i:]
+	}
+	return obj, nil
+}
+
+func streamObj(dict string, data []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+func pdfDate(t time.Time) string { return "D:" + t.UTC().Format("20060102150405") + "Z" }
+
+// pdfName returns s as pdf name with irregular characters escaped.
+func pdfName(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// pdfStr returns s as literal pdf string.
+func pdfStr(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// utf16Str returns s as hex pdf string in utf-16 encoding with byte order mark.
+func utf16Str(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func xmlEsc(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+	return r.Replace(s)
+}
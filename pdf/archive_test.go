@@ -0,0 +1,211 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+)
+
+func TestArchive(t *testing.T) {
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	n := &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 800, H: 400}},
+		List: []*layla.Node{
+			{Kind: "markup", Data: "Rechnung [online](https://example.com/r/1)"},
+		},
+	}
+	render := func() *Doc {
+		d, err := Render(man, n)
+		if err != nil {
+			t.Fatalf("render error: %v", err)
+		}
+		return d
+	}
+	created := time.Date(2019, time.October, 5, 23, 0, 0, 0, time.UTC)
+	xml := []byte("<rsm:CrossIndustryInvoice/>")
+	a := &Archive{Level: PDFA3B, Meta: Meta{Title: "Rechnung", Created: created},
+		Files: []File{{Name: "factur-x.xml", Mime: "text/xml", Rel: "Data", Data: xml}},
+	}
+	var b bytes.Buffer
+	err := a.Output(&b, render())
+	if err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	out := b.Bytes()
+	x, err := readXref(out)
+	if err != nil {
+		t.Fatalf("read archive error: %v", err)
+	}
+	// check the xref offsets
+	i := bytes.LastIndex(out, []byte("\nxref\n"))
+	lines := strings.Split(string(out[i+6:]), "\n")[2:]
+	for n := 1; n < x.n; n++ {
+		var off int
+		fmt.Sscanf(lines[n-1], "%d", &off)
+		if want := fmt.Sprintf("%d 0 obj\n", n); !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Errorf("xref offset for object %d is wrong", n)
+		}
+	}
+	for _, want := range []string{
+		"%PDF-1.7\n%\xe2\xe3\xcf\xd3\n",
+		"/Subtype /Link /F 4 ",
+		"/CreationDate (D:20191005230000Z)",
+		"<pdfaid:part>3</pdfaid:part>",
+		"<xmp:CreateDate>2019-10-05T23:00:00Z</xmp:CreateDate>",
+		"/S /GTS_PDFA1",
+		"/Subtype /text#2Fxml",
+		"/AFRelationship /Data",
+		"/AF [",
+		"/OutputIntents [",
+		"/ID [<",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("want %q in archive output", want)
+		}
+	}
+	if !bytes.Contains(out, xml) {
+		t.Errorf("want uncompressed associated file")
+	}
+	a.Level = PDFA2B
+	err = a.Output(&b, render())
+	if err == nil {
+		t.Errorf("want error for associated files in pdf/a-2b")
+	}
+	a.Files = nil
+	d := render()
+	d.SetAttachments([]gofpdf.Attachment{{Content: xml, Filename: "factur-x.xml"}})
+	err = a.Output(&b, d)
+	if err == nil {
+		t.Errorf("want error for files attached by gofpdf")
+	}
+	d = render()
+	d.SetProtection(0, "", "owner")
+	err = a.Output(&b, d)
+	if err == nil {
+		t.Errorf("want error for encrypted pdf/a")
+	}
+}
+
+// rawPdf returns a pdf document with the objects objs, the first is the catalog and the second
+// the document information.
+func rawPdf(objs ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offs := make([]int, len(objs))
+	for i, o := range objs {
+		offs[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, o := range offs {
+		fmt.Fprintf(&b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&b, "trailer\n<<\n/Size %d\n/Root 1 0 R\n/Info 2 0 R\n>>\n", len(objs)+1)
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+func TestXref(t *testing.T) {
+	// stream data that looks like pdf syntax must survive the rewrite unchanged
+	data := "endobj\n9 0 obj\n<< /Subtype /Link >>\nstream\nxref\ntrailer\n"
+	stream := fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(data), data)
+	raw := rawPdf(
+		"<<\n/Type /Catalog\n/Pages 4 0 R\n>>",
+		"<<\n/Producer (test)\n>>",
+		stream,
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 1 1] >>",
+	)
+	x, err := readXref(raw)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if err = x.check(); err != nil {
+		t.Fatalf("check error: %v", err)
+	}
+	x.fix(time.Now())
+	x, err = readXref(x.bytes())
+	if err != nil {
+		t.Fatalf("read rewritten error: %v", err)
+	}
+	if got := string(x.objs[3]); got != stream {
+		t.Errorf("want stream unchanged got %q", got)
+	}
+	if !bytes.Contains(x.objs[4], []byte("/Subtype /Link /F 4 ")) {
+		t.Errorf("want print flag for link annotation got %s", x.objs[4])
+	}
+	errs := []struct {
+		name string
+		raw  []byte
+	}{
+		{"free entry", bytes.Replace(raw, []byte(" 00000 n \n"), []byte(" 00000 f \n"), 1)},
+		{"wrong offset", bytes.Replace(raw, []byte("0000000009 00000 n"), []byte("0000000010 00000 n"), 1)},
+		{"no trailer", bytes.Replace(raw, []byte("/Root 1 0 R"), []byte{}, 1)},
+	}
+	for _, e := range errs {
+		if _, err := readXref(e.raw); err == nil {
+			t.Errorf("%s: want read error", e.name)
+		}
+	}
+	x, err = readXref(rawPdf("<<\n/Type /Catalog\n>>", "<<>>",
+		"<< /Type /EmbeddedFile /Length 1 >>\nstream\nx\nendstream"))
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if err = x.check(); err == nil {
+		t.Errorf("want check error for embedded file stream")
+	}
+}
+
+// TestVeraPDF validates an archive with the veraPDF command line tool if it is installed, because
+// the tool requires a java runtime it is not a test dependency.
+func TestVeraPDF(t *testing.T) {
+	cmd, err := exec.LookPath("verapdf")
+	if err != nil {
+		t.Skip("verapdf not installed")
+	}
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	n := &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 800, H: 400}},
+		List: []*layla.Node{
+			{Kind: "markup", Data: "# Rechnung\nSiehe [online](https://example.com/r/1)"},
+		},
+	}
+	for _, lvl := range []Level{PDFA2B, PDFA3B} {
+		d, err := Render(man, n)
+		if err != nil {
+			t.Fatalf("render error: %v", err)
+		}
+		a := &Archive{Level: lvl, Meta: Meta{Title: "Rechnung", Author: "Firma GmbH"}}
+		if lvl == PDFA3B {
+			a.Files = []File{{Name: "data.xml", Mime: "text/xml", Rel: "Data",
+				Data: []byte("<data/>")}}
+		}
+		var b bytes.Buffer
+		if err = a.Output(&b, d); err != nil {
+			t.Fatalf("archive error: %v", err)
+		}
+		path := filepath.Join(t.TempDir(), "archive.pdf")
+		if err = ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		flavour := strings.ToLower(string(lvl))
+		out, err := exec.Command(cmd, "--flavour", flavour, "--format", "text", path).CombinedOutput()
+		if err != nil || !bytes.HasPrefix(out, []byte("PASS")) {
+			t.Errorf("pdf/a-%s validation failed: %v\n%s", flavour, err, out)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// srgbProfile is a small icc v2 display profile for the sRGB color space used as pdf/a output
+// intent. The primaries are chromatically adapted to the D50 profile connection space.
+var srgbProfile = buildSRGB()
+
+func buildSRGB() []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	trc := curv(1024, func(v float64) float64 {
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	})
+	tags := []tag{
+		{"desc", desc("sRGB IEC61966-2.1")},
+		{"cprt", text("No copyright, use freely")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4360747, 0.2225045, 0.0139322)},
+		{"gXYZ", xyz(0.3850649, 0.7168786, 0.0971045)},
+		{"bXYZ", xyz(0.1430804, 0.0606169, 0.7141733)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+	off := 128 + 4 + 12*len(tags)
+	table := make([]byte, 4, 4+12*len(tags))
+	binary.BigEndian.PutUint32(table, uint32(len(tags)))
+	var data []byte
+	offs := make(map[*byte]int)
+	for _, t := range tags {
+		// identical tag data is shared
+		o, ok := offs[&t.data[0]]
+		if !ok {
+			o = off + len(data)
+			offs[&t.data[0]] = o
+			data = append(data, t.data...)
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		table = append(table, t.sig...)
+		table = be32(table, uint32(o))
+		table = be32(table, uint32(len(t.data)))
+	}
+	h := make([]byte, 128)
+	binary.BigEndian.PutUint32(h[0:], uint32(off+len(data)))
+	binary.BigEndian.PutUint32(h[8:], 0x02100000)
+	copy(h[12:], "mntrRGB XYZ ")
+	// creation date 2019-01-01
+	for i, v := range []uint16{2019, 1, 1} {
+		binary.BigEndian.PutUint16(h[24+2*i:], v)
+	}
+	copy(h[36:], "acsp")
+	copy(h[68:], xyz(0.9642, 1.0, 0.8249)[8:])
+	res := append(h, table...)
+	return append(res, data...)
+}
+
+func be32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func s15f16(v float64) uint32 { return uint32(int32(math.Round(v * 65536))) }
+
+func xyz(x, y, z float64) []byte {
+	b := append([]byte("XYZ "), 0, 0, 0, 0)
+	for _, v := range []float64{x, y, z} {
+		b = be32(b, s15f16(v))
+	}
+	return b
+}
+
+func curv(n int, f func(float64) float64) []byte {
+	b := append([]byte("curv"), 0, 0, 0, 0)
+	b = be32(b, uint32(n))
+	for i := 0; i < n; i++ {
+		v := f(float64(i) / float64(n-1))
+		u := uint16(math.Round(v * 65535))
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return b
+}
+
+func text(s string) []byte {
+	b := append([]byte("text"), 0, 0, 0, 0)
+	return append(append(b, s...), 0)
+}
+
+func desc(s string) []byte {
+	b := append([]byte("desc"), 0, 0, 0, 0)
+	b = be32(b, uint32(len(s)+1))
+	b = append(append(b, s...), 0)
+	// empty unicode and scriptcode descriptions
+	b = append(b, make([]byte, 4+4+2+1+67)...)
+	return b
+}
@@ -0,0 +1,91 @@
+package pdf
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/mark"
+)
+
+// Meta holds the document information of a pdf document.
+type Meta struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords []string
+	Created  time.Time
+}
+
+// Set sets the non-zero document information of m on the document d.
+func (m *Meta) Set(d *Doc) {
+	if m.Title != "" {
+		d.SetTitle(m.Title, true)
+	}
+	if m.Author != "" {
+		d.SetAuthor(m.Author, true)
+	}
+	if m.Subject != "" {
+		d.SetSubject(m.Subject, true)
+	}
+	if len(m.Keywords) > 0 {
+		d.SetKeywords(strings.Join(m.Keywords, " "), true)
+	}
+	if !m.Created.IsZero() {
+		d.SetCreationDate(m.Created)
+	}
+}
+
+// outline is a bookmark entry for a header in the display list.
+type outline struct {
+	Title string
+	Level int
+	Y     float64
+}
+
+// outlines returns a map of display list indices to outline entries.
+// Consecutive text nodes with the same header style, as produced from one markup header, are
+// joined to one entry at the index of the last node.
+func outlines(draw []*layla.Node) map[int]*outline {
+	var res map[int]*outline
+	var cur *outline
+	var last int
+	for i, d := range draw {
+		tag := headerTag(d)
+		if tag == 0 {
+			cur = nil
+			continue
+		}
+		if cur != nil && tag == headerTag(draw[last]) {
+			p := draw[last]
+			if d.Y != p.Y || d.X > p.X+p.W {
+				cur.Title += " "
+			}
+			cur.Title += d.Data
+			delete(res, last)
+		} else {
+			cur = &outline{Title: d.Data, Level: headerLevel(tag), Y: d.Y}
+		}
+		if res == nil {
+			res = make(map[int]*outline)
+		}
+		res[i] = cur
+		last = i
+	}
+	return res
+}
+
+func headerTag(d *layla.Node) mark.Tag {
+	if d.Kind != "text" || d.Font == nil {
+		return 0
+	}
+	return d.Font.Style & mark.Header
+}
+
+func headerLevel(tag mark.Tag) (l int) {
+	for tag > mark.H1 {
+		tag >>= 1
+		l++
+	}
+	return l
+}
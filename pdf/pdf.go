@@ -7,7 +7,6 @@ import (
 	"image"
 	"image/color"
 	"image/png"
-	"path/filepath"
 
 	"github.com/boombuler/barcode"
 	"github.com/jung-kurt/gofpdf"
@@ -15,16 +14,16 @@ import (
 	"github.com/mb0/layla/bcode"
 	"github.com/mb0/layla/font"
 	"github.com/mb0/xelf/cor"
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/transform"
 )
 
 type Doc = gofpdf.Fpdf
 
 func NewDoc(n *layla.Node) *Doc {
+	rot, _ := layla.NormRot(n.Rot)
+	dim := layla.RotDim(n.Dim, rot)
 	doc := gofpdf.NewCustom(&gofpdf.InitType{
 		UnitStr: "mm",
-		Size:    gofpdf.SizeType{n.W / 8, n.H / 8},
+		Size:    gofpdf.SizeType{dim.W / 8, dim.H / 8},
 	})
 	doc.SetAutoPageBreak(false, 0)
 	return doc
@@ -36,7 +35,7 @@ func NewA4() *Doc {
 }
 
 func Render(m *font.Manager, n *layla.Node) (*Doc, error) {
-	return Renderer{m, nil}.RenderTo(NewDoc(n), n)
+	return Renderer{Manager: m}.RenderTo(NewDoc(n), n)
 }
 
 type colorhack struct{ image.Image }
@@ -58,6 +57,11 @@ func DefaultBarcoder(n *layla.Node) (image.Image, error) {
 type Renderer struct {
 	*font.Manager
 	Barcoder func(*layla.Node) (image.Image, error)
+	// Meta is set as document information if not nil.
+	Meta *Meta
+	// Debug draws dashed outlines of the margin, padding and content boxes of container nodes
+	// labeled with the node path.
+	Debug bool
 }
 
 func (r Renderer) RenderTo(d *Doc, n *layla.Node) (*Doc, error) {
@@ -65,28 +69,63 @@ func (r Renderer) RenderTo(d *Doc, n *layla.Node) (*Doc, error) {
 }
 
 func (r Renderer) RenderSubjTo(d *Doc, n *layla.Node, subj string) (*Doc, error) {
-	d.AddPage()
-	if subj != "" {
-		subj, err := enc(subj)
-		if err == nil {
-			d.Bookmark(subj, 0, 0)
-		}
+	l := layla.NewLayouter(r.Manager)
+	l.Debug = r.Debug
+	draw, err := l.LayoutAndPage(n)
+	if err != nil {
+		return nil, err
 	}
-	draw, err := layla.LayoutAndPage(r.Manager, n)
+	_, err = layla.Rotate(draw, n.Dim, n.Rot)
 	if err != nil {
 		return nil, err
 	}
-	r.addFonts(d, draw)
-	for _, dn := range draw {
-		err = r.renderNode(d, dn)
+	fam, err := r.addFonts(d, draw)
+	if err != nil {
+		return nil, err
+	}
+	lfam := fam
+	if r.Debug && lfam == "" {
+		// debug labels need an embedded font for pdf/a
+		lfam, err = r.addFonts(d, []*layla.Node{{Font: &layla.Font{}}})
 		if err != nil {
 			return nil, err
 		}
 	}
+	if r.Meta != nil {
+		r.Meta.Set(d)
+	}
+	d.AddPage()
+	// header outlines are nested below the subject bookmark
+	var lvl int
+	if subj != "" {
+		// gofpdf only encodes bookmarks as unicode if the current font is a utf-8 font
+		if fam != "" {
+			d.SetFont(fam, "", 0)
+		}
+		d.Bookmark(subj, 0, 0)
+		lvl = 1
+	}
+	outs := outlines(draw)
+	for i, dn := range draw {
+		if dn.Link != "" && dn.Kind != "page" {
+			d.LinkString(dn.X/8, dn.Y/8, dn.W/8, dn.H/8, dn.Link)
+		}
+		err = r.renderNode(d, dn, lfam)
+		if err != nil {
+			return nil, err
+		}
+		if o := outs[i]; o != nil {
+			// the current font is the utf-8 font of the header node
+			d.Bookmark(o.Title, lvl+o.Level, o.Y/8)
+		}
+	}
 	return d, d.Error()
 }
 
-func (r Renderer) addFonts(d *Doc, ns []*layla.Node) error {
+// addFonts embeds all fonts used in the display list ns as subset utf-8 truetype fonts.
+// It returns the family name of the first font or an empty string.
+func (r Renderer) addFonts(d *Doc, ns []*layla.Node) (string, error) {
+	var first string
 	fs := make(map[string]bool, 8)
 	for _, n := range ns {
 		if n.Font == nil {
@@ -95,18 +134,27 @@ func (r Renderer) addFonts(d *Doc, ns []*layla.Node) error {
 		if fs[n.Font.Name] {
 			continue
 		}
-		path, err := r.Path(n.Font.Name)
+		src, err := r.Source(n.Font.Name)
 		if err != nil {
-			return err
+			return "", err
+		}
+		fam := family(n.Font.Name)
+		d.AddUTF8FontFromBytes(fam, "", src.Data)
+		if first == "" {
+			first = fam
 		}
-		dir, fname := filepath.Split(path)
-		d.SetFontLocation(dir)
-		ext := filepath.Ext(fname)
-		descf := fmt.Sprintf("%s.json", fname[:len(fname)-len(ext)])
-		d.AddFont(n.Font.Name, "", descf)
 		fs[n.Font.Name] = true
 	}
-	return nil
+	return first, d.Error()
+}
+
+// family returns the pdf font family for a registered font name.
+// The empty default font name is used as current family by gofpdf and must be replaced.
+func family(name string) string {
+	if name == "" {
+		return "layla"
+	}
+	return name
 }
 
 func setupBorder(d *Doc, bw float64, c *layla.Color) float64 {
@@ -143,37 +191,52 @@ func drawBorder(d *Doc, b layla.Box, br layla.Border, c *layla.Color) {
 	}
 }
 
-func (r Renderer) renderNode(d *Doc, n *layla.Node) error {
+// renderNode draws the display node n to d. Debug labels use the font family lfam.
+func (r Renderer) renderNode(d *Doc, n *layla.Node, lfam string) error {
+	switch n.Kind {
+	case "text", "barcode", "qrcode":
+		if n.Rot != 0 {
+			// draw the unrotated content rotated around its center
+			c := layla.Unrot(n.Box, n.Rot)
+			d.TransformBegin()
+			d.TransformRotate(-float64(n.Rot), (c.X+c.W/2)/8, (c.Y+c.H/2)/8)
+			defer d.TransformEnd()
+			// work on a copy to leave the callers display node unchanged
+			cp := *n
+			cp.Box = c
+			n = &cp
+		}
+	}
 	switch n.Kind {
 	case "ellipse":
 		b := n.Border.Default(1.6)
-		d.SetLineWidth(b.W / 8)
+		setupBorder(d, b.W, n.Color)
 		rx, ry := n.W/16, n.H/16
 		d.Ellipse(n.X/8+rx, n.Y/8+ry, rx, ry, 0, "D")
 	case "line":
 		b := n.Border.Default(1.6)
-		d.SetDrawColor(0, 0, 0)
-		d.SetLineWidth(b.W / 8)
+		setupBorder(d, b.W, n.Color)
 		x, y := n.X/8, n.Y/8
 		d.Line(x, y, x+n.W/8, y+n.H/8)
 	case "rect":
 		b := n.Border.Default(1.6)
-		drawBorder(d, n.Box, b, nil)
+		drawBorder(d, n.Box, b, n.Color)
 	case "text":
 		br := n.Border.Default(0)
-		drawBorder(d, n.Box, br, nil)
+		drawBorder(d, n.Box, br, n.Color)
+		if c := n.Color; c != nil {
+			d.SetTextColor(c.R, c.G, c.B)
+		} else {
+			d.SetTextColor(0, 0, 0)
+		}
 
 		fsize := n.Font.Size
 		// XXX hack until i figure out the difference in font size between printer and pdf
 		if r.DPI() >= 200 {
 			fsize -= 1
 		}
-		d.SetFont(n.Font.Name, "", fsize)
+		d.SetFont(family(n.Font.Name), "", fsize)
 		b := n.Pad.Inset(n.Box)
-		res, err := enc(n.Data)
-		if err != nil {
-			return err
-		}
 		x, w, align := b.X, b.W, ""
 		switch n.Align {
 		case layla.AlignRight:
@@ -190,7 +253,7 @@ func (r Renderer) renderNode(d *Doc, n *layla.Node) error {
 			w += 16
 		}
 		d.SetXY(x/8, b.Y/8)
-		d.MultiCell(w/8, n.Font.Line/8, res, "", align, false)
+		d.MultiCell(w/8, n.Font.Line/8, n.Data, "", align, false)
 	case "barcode", "qrcode":
 		coder := r.Barcoder
 		if coder == nil {
@@ -209,6 +272,16 @@ func (r Renderer) renderNode(d *Doc, n *layla.Node) error {
 		iopt := gofpdf.ImageOptions{ImageType: "PNG"}
 		d.RegisterImageOptionsReader(name, iopt, &b)
 		d.ImageOptions(name, n.X/8, n.Y/8, n.W/8, n.H/8, false, iopt, 0, "")
+	case "debug":
+		setupBorder(d, 0.8, n.Color)
+		d.SetDashPattern([]float64{0.5, 0.5}, 0)
+		d.Rect(n.X/8, n.Y/8, n.W/8, n.H/8, "D")
+		d.SetDashPattern(nil, 0)
+		if n.Data != "" {
+			d.SetFont(lfam, "", 4)
+			d.SetTextColor(n.Color.R, n.Color.G, n.Color.B)
+			d.Text(n.X/8+0.3, n.Y/8+1.6, n.Data)
+		}
 	case "page":
 		d.AddPage()
 	default:
@@ -216,10 +289,3 @@ func (r Renderer) renderNode(d *Doc, n *layla.Node) error {
 	}
 	return nil
 }
-
-var win1252Enc = charmap.Windows1252.NewEncoder()
-
-func enc(str string) (string, error) {
-	res, _, err := transform.String(win1252Enc, str)
-	return res, err
-}
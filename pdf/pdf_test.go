@@ -0,0 +1,169 @@
+package pdf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+	"github.com/mb0/layla/mark"
+)
+
+func TestOutlines(t *testing.T) {
+	head := func(tag mark.Tag, x, y float64, data string) *layla.Node {
+		return &layla.Node{Kind: "text", Box: layla.Box{Pos: layla.Pos{X: x, Y: y},
+			Dim: layla.Dim{W: 50, H: 40}}, Font: &layla.Font{Style: tag}, Data: data}
+	}
+	draw := []*layla.Node{
+		head(mark.H1, 0, 0, "Data"),
+		head(mark.H1|mark.B, 60, 0, "Sheet"),
+		head(mark.H1, 0, 40, "Two"),
+		head(0, 0, 80, "Text"),
+		head(mark.H2, 0, 120, "Usage"),
+	}
+	got := outlines(draw)
+	want := map[int]outline{
+		2: {"Data Sheet Two", 0, 0},
+		4: {"Usage", 1, 120},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d outlines got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		if g := got[i]; g == nil || *g != w {
+			t.Errorf("outline %d want %v got %v", i, w, g)
+		}
+	}
+}
+
+func TestMetaLinks(t *testing.T) {
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	n := &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 800, H: 400}},
+		List: []*layla.Node{
+			{Kind: "markup", Data: "# Überblick"},
+			{Kind: "markup", Box: layla.Box{Pos: layla.Pos{Y: 100}},
+				Data: "See [docs](https://example.com/docs)"},
+			{Kind: "rect", Box: layla.Box{Pos: layla.Pos{Y: 200}, Dim: layla.Dim{H: 100}},
+				Link: "https://example.com/rect"},
+		},
+	}
+	r := Renderer{Manager: man, Meta: &Meta{
+		Title:    "Datenblatt",
+		Author:   "Firma GmbH",
+		Keywords: []string{"layla", "pdf"},
+		Created:  time.Date(2019, time.October, 5, 23, 0, 0, 0, time.UTC),
+	}}
+	d, err := r.RenderSubjTo(NewDoc(n), n, "Produkt")
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	d.SetCompression(false)
+	var b bytes.Buffer
+	err = d.Output(&b)
+	if err != nil {
+		t.Fatalf("output error: %v", err)
+	}
+	for _, want := range []string{
+		"/URI (https://example.com/docs)",
+		"/URI (https://example.com/rect)",
+		"/Title (\xfe\xff", "/Author (\xfe\xff", "/Keywords (\xfe\xff",
+		"/CreationDate (D:20191005230000)",
+		"/Outlines",
+	} {
+		if !bytes.Contains(b.Bytes(), []byte(want)) {
+			t.Errorf("want %s in pdf output", want)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rot  int
+		want []string
+	}{
+		{0, []string{
+			"/MediaBox [0 0 141.73 70.87]",
+			"14.46 65.20 m 14.46 51.02 l S",
+			"BT 70.87 34.55 Td (",
+		}},
+		{90, []string{
+			"/MediaBox [0 0 70.87 141.73]",
+			"51.31 127.56 m 51.31 99.21 l S",
+			"0.00000 -1.00000 1.00000 0.00000 -11.87008 87.34252 cm",
+			"BT 16.48 46.42 Td (",
+		}},
+		{180, []string{
+			"/MediaBox [0 0 141.73 70.87]",
+			"99.50 19.84 m 99.50 5.67 l S",
+			"-1.00000 -0.00000 0.00000 -1.00000 99.21260 66.25984 cm",
+			"BT 28.35 29.95 Td (",
+		}},
+		{270, []string{
+			"/MediaBox [0 0 70.87 141.73]",
+			"5.95 42.52 m 5.95 14.17 l S",
+			"-0.00000 1.00000 -1.00000 -0.00000 125.25591 58.99606 cm",
+			"BT 11.87 88.94 Td (",
+		}},
+	}
+	for _, test := range tests {
+		n := &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 400, H: 200}},
+			Font: &layla.Font{Size: 8},
+			List: []*layla.Node{
+				{Kind: "rect", Box: layla.Box{Pos: layla.Pos{X: 40, Y: 16},
+					Dim: layla.Dim{W: 80, H: 40}}},
+				{Kind: "text", Box: layla.Box{Pos: layla.Pos{X: 200, Y: 80},
+					Dim: layla.Dim{W: 120}}, Data: "Test"},
+			},
+		}
+		n.Rot = test.rot
+		d, err := Render(man, n)
+		if err != nil {
+			t.Errorf("rot %d render error: %v", test.rot, err)
+			continue
+		}
+		d.SetCompression(false)
+		var b bytes.Buffer
+		err = d.Output(&b)
+		if err != nil {
+			t.Errorf("rot %d output error: %v", test.rot, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !bytes.Contains(b.Bytes(), []byte(want)) {
+				t.Errorf("rot %d want %s in pdf output", test.rot, want)
+			}
+		}
+	}
+}
+
+func TestDebugFont(t *testing.T) {
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	n := &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 400, H: 200}},
+		List: []*layla.Node{
+			{Kind: "vbox", List: []*layla.Node{
+				{Kind: "rect", Box: layla.Box{Dim: layla.Dim{H: 40}}},
+			}},
+		},
+	}
+	d, err := Renderer{Manager: man, Debug: true}.RenderTo(NewDoc(n), n)
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	// debug labels must not use core fonts, that are not allowed in pdf/a
+	var b bytes.Buffer
+	err = (&Archive{Level: PDFA2B}).Output(&b, d)
+	if err != nil {
+		t.Errorf("archive error: %v", err)
+	}
+}
@@ -0,0 +1,374 @@
+// Package preview implements a http server for live previews of layla templates.
+//
+// The server renders the templates of a directory as html or pdf and reloads open previews
+// using server sent events, whenever a file in the directory changes. Sample data for the
+// template label.layla is read from label.json or another json file selected with the data query
+// parameter. The locale query parameter selects the locale for formatting and translations.
+// Errors are shown inline with the source line and path of the failing node. The debug query
+// parameter outlines the boxes of container nodes.
+//
+// Previews are only served as html and pdf, because layla has no svg or png renderer.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+	lhtml "github.com/mb0/layla/html"
+	"github.com/mb0/layla/pdf"
+	"github.com/mb0/xelf/cor"
+	"github.com/mb0/xelf/exp"
+	"github.com/mb0/xelf/lit"
+)
+
+// Server serves live previews of the layla templates in directory Dir.
+type Server struct {
+	Dir string
+	*font.Manager
+	// Catalogs holds optional translation catalogs used for the tr form.
+	Catalogs *layla.Catalogs
+	// Interval is the poll interval used to watch for file changes. It defaults to 300ms.
+	Interval time.Duration
+
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+// New returns a new preview server for the templates in dir using the fonts of man.
+func New(dir string, man *font.Manager) *Server {
+	return &Server{Dir: dir, Manager: man}
+}
+
+// ListenAndServe watches the template directory and serves the previews on addr.
+// Errors watching the directory are logged.
+func (s *Server) ListenAndServe(addr string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		err := s.Watch(ctx)
+		if err != nil && err != context.Canceled {
+			log.Printf("watch %s: %v", s.Dir, err)
+		}
+	}()
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Path
+	switch {
+	case p == "/":
+		s.index(w, r)
+	case p == "/events":
+		s.events(w, r)
+	case strings.HasPrefix(p, "/view/"):
+		s.view(w, r, p[6:])
+	case strings.HasPrefix(p, "/pdf/"):
+		s.pdf(w, r, p[5:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Watch polls the template directory for changes and notifies open previews until ctx is done.
+// It returns an error if the directory cannot be read initially. Later errors are logged once
+// until the directory can be read again, because files may be removed while polling.
+func (s *Server) Watch(ctx context.Context) error {
+	d := s.Interval
+	if d <= 0 {
+		d = 300 * time.Millisecond
+	}
+	last, err := s.stamp()
+	if err != nil {
+		return err
+	}
+	t := time.NewTicker(d)
+	defer t.Stop()
+	var failed bool
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		cur, err := s.stamp()
+		if err != nil {
+			if !failed {
+				log.Printf("watch %s: %v", s.Dir, err)
+			}
+			failed = true
+			continue
+		}
+		failed = false
+		if cur != last {
+			last = cur
+			s.notify()
+		}
+	}
+}
+
+// stamp returns a string that changes whenever a file in the directory is added, removed or
+// modified.
+func (s *Server) stamp() (string, error) {
+	var b strings.Builder
+	err := fs.WalkDir(os.DirFS(s.Dir), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", p, fi.Size(), fi.ModTime().UnixNano())
+		return nil
+	})
+	return b.String(), err
+}
+
+func (s *Server) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan struct{}, 1)
+	s.mu.Lock()
+	if s.clients == nil {
+		s.clients = make(map[chan struct{}]struct{})
+	}
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c:
+			fmt.Fprint(w, "event: reload\ndata: \n\n")
+			f.Flush()
+		}
+	}
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	names, err := fs.Glob(os.DirFS(s.Dir), "*.layla")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var b bytes.Buffer
+	head(&b, "layla preview")
+	b.WriteString("<ul>\n")
+	for _, name := range names {
+		e := html.EscapeString(name)
+		fmt.Fprintf(&b, `<li><a href="/view/%s">%s</a>`, e, e)
+		for _, data := range s.dataFiles(name) {
+			d := html.EscapeString(url.QueryEscape(data))
+			fmt.Fprintf(&b, ` <a href="/view/%s?data=%s">%s</a>`, e, d, html.EscapeString(data))
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+	foot(&b)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+func (s *Server) view(w http.ResponseWriter, r *http.Request, name string) {
+	if !s.exists(name) {
+		http.NotFound(w, r)
+		return
+	}
+	var b bytes.Buffer
+	head(&b, name)
+	q := r.URL.RawQuery
+	if q != "" {
+		q = "?" + q
+	}
+	dq := r.URL.Query()
+	if dq.Get("debug") != "" {
+		dq.Del("debug")
+	} else {
+		dq.Set("debug", "1")
+	}
+	fmt.Fprintf(&b, `<nav><a href="/">index</a> %s <a href="/pdf/%[1]s%s">pdf</a>`+
+		` <a href="/view/%[1]s?%s">debug</a></nav>`+"\n",
+		html.EscapeString(name), html.EscapeString(q), html.EscapeString(dq.Encode()))
+	n, err := s.execute(name, r.URL.Query())
+	if err == nil {
+		var out bytes.Buffer
+		debug := r.URL.Query().Get("debug") != ""
+		err = lhtml.Renderer{Manager: s.Manager, Debug: debug}.RenderBfr(&out, n)
+		if err == nil {
+			b.Write(out.Bytes())
+		}
+	}
+	if err != nil {
+		s.writeError(&b, name, err)
+	}
+	foot(&b)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+func (s *Server) pdf(w http.ResponseWriter, r *http.Request, name string) {
+	if !s.exists(name) {
+		http.NotFound(w, r)
+		return
+	}
+	n, err := s.execute(name, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	doc, err := pdf.Render(s.Manager, n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	var b bytes.Buffer
+	err = doc.Output(&b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(b.Bytes())
+}
+
+func (s *Server) exists(name string) bool {
+	if path.Ext(name) != ".layla" || !fs.ValidPath(name) {
+		return false
+	}
+	fi, err := fs.Stat(os.DirFS(s.Dir), name)
+	return err == nil && !fi.IsDir()
+}
+
+// execute loads the template with name and the sample data and locale selected by query q.
+func (s *Server) execute(name string, q url.Values) (*layla.Node, error) {
+	fsys := os.DirFS(s.Dir)
+	l := layla.NewLoader(fsys)
+	loc := q.Get("locale")
+	env := layla.LocaleEnv(loc)
+	if s.Catalogs != nil {
+		env = s.Catalogs.Env(loc)
+	}
+	l.Env = append(exp.Builtin{l.Lookup}, env...)
+	data := q.Get("data")
+	if data == "" {
+		data = strings.TrimSuffix(name, ".layla") + ".json"
+		if _, err := fs.Stat(fsys, data); err != nil {
+			return l.Execute(name, nil)
+		}
+	}
+	f, err := fsys.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	param, err := lit.Read(f)
+	if err != nil {
+		return nil, cor.Errorf("data %s: %v", data, err)
+	}
+	return l.Execute(name, param)
+}
+
+// dataFiles returns the json files starting with the template name without extension.
+func (s *Server) dataFiles(name string) []string {
+	base := strings.TrimSuffix(name, ".layla")
+	res, _ := fs.Glob(os.DirFS(s.Dir), base+"*.json")
+	sort.Strings(res)
+	return res
+}
+
+// writeError writes err as html to b. Node errors show the source line of the failing node.
+func (s *Server) writeError(b *bytes.Buffer, name string, err error) {
+	b.WriteString(`<div class="error">`)
+	var ne *layla.NodeError
+	if errors.As(err, &ne) {
+		src := ne.Src
+		if src.File == "" {
+			src.File = name
+		}
+		fmt.Fprintf(b, "<p><b>%s</b> %s</p>\n", html.EscapeString(src.String()),
+			html.EscapeString(ne.Path))
+		if line := s.sourceLine(src); line != "" {
+			fmt.Fprintf(b, "<pre>%4d | %s\n", src.Line, html.EscapeString(line))
+			if src.Col > 0 {
+				fmt.Fprintf(b, "     | %s^\n", strings.Repeat(" ", src.Col-1))
+			}
+			b.WriteString("</pre>\n")
+		}
+		err = ne.Err
+	}
+	fmt.Fprintf(b, "<p>%s</p></div>\n", html.EscapeString(err.Error()))
+}
+
+// sourceLine returns the line of the source position src with tabs replaced by spaces.
+func (s *Server) sourceLine(src layla.Src) string {
+	if src.Line <= 0 || !fs.ValidPath(src.File) {
+		return ""
+	}
+	raw, err := fs.ReadFile(os.DirFS(s.Dir), src.File)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(raw), "\n")
+	if src.Line > len(lines) {
+		return ""
+	}
+	return strings.Replace(strings.TrimRight(lines[src.Line-1], "\r"), "\t", " ", -1)
+}
+
+func head(b *bytes.Buffer, title string) {
+	fmt.Fprintf(b, `<!doctype html>
+<html><head><meta charset="utf-8"><title>%s</title>
+<style>
+body { background-color: grey; font-family: sans-serif }
+nav, ul, .error { margin: 10mm; padding: 8px; background-color: white }
+.error { border-left: 4px solid #c00 }
+.error pre { overflow-x: auto }
+</style></head><body>
+`, html.EscapeString(title))
+}
+
+func foot(b *bytes.Buffer) {
+	b.WriteString(`<script>
+new EventSource('/events').addEventListener('reload', function() { location.reload() })
+</script>
+</body></html>
+`)
+}
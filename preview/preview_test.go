@@ -0,0 +1,94 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+	"github.com/mb0/xelf/cor"
+)
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("label.layla", "(stage w:360 h:360\n\t(text 'hello'))\n")
+	write("label.json", "{}")
+	write("label.de.json", "{}")
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	s := New(dir, man)
+	s.Interval = 10 * time.Millisecond
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	get := func(p string) (int, string) {
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+	code, body := get("/")
+	if code != 200 || !strings.Contains(body, `href="/view/label.layla"`) ||
+		!strings.Contains(body, `href="/view/label.layla?data=label.de.json"`) {
+		t.Errorf("unexpected index %d: %s", code, body)
+	}
+	for _, p := range []string{"/view/missing.layla", "/view/label.json", "/pdf/../x.layla"} {
+		if code, _ := get(p); code != 404 {
+			t.Errorf("want not found for %s got %d", p, code)
+		}
+	}
+	code, body = get("/view/label.layla")
+	if code != 200 || !strings.Contains(body, ">hello</div>") || strings.Contains(body, `class="error"`) {
+		t.Errorf("unexpected view %d: %s", code, body)
+	}
+	code, body = get("/pdf/label.layla")
+	if code != 200 || !strings.HasPrefix(body, "%PDF-") {
+		t.Errorf("unexpected pdf %d: %.200s", code, body)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx)
+	res, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("want event stream got %s", ct)
+	}
+	time.Sleep(30 * time.Millisecond)
+	write("label.layla", "(stage w:360 h:360\n\t(text 'changed'))\n")
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil || line != "event: reload\n" {
+		t.Errorf("want reload event got %q %v", line, err)
+	}
+	var b bytes.Buffer
+	s.writeError(&b, "label.layla", &layla.NodeError{
+		Src:  layla.Src{Line: 2, Col: 2},
+		Path: "stage/text",
+		Err:  cor.Errorf("bad text"),
+	})
+	want := "<p><b>label.layla:2:2</b> stage/text</p>\n" +
+		"<pre>   2 |  (text &#39;changed&#39;))\n     |  ^\n</pre>\n<p>bad text</p>"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("want error %s\ngot %s", want, b.String())
+	}
+}
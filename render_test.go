@@ -33,6 +33,7 @@ var testFiles = []string{
 	"pages",
 	"label1",
 	"label2",
+	"unicode",
 }
 
 func TestHtml(t *testing.T) {
@@ -92,7 +93,11 @@ func read(name string) (*layla.Node, error) {
 		{"batch", lit.Str("AB19020501")},
 		{"ingreds", lit.Str("list of all the ingredients, like suger and spice and everthing nice.")},
 	})
-	env := &exp.ParamEnv{layla.Env, param}
+	cats, err := layla.LoadCatalogs(os.DirFS("testdata/i18n"), "en")
+	if err != nil {
+		return nil, err
+	}
+	env := &exp.ParamEnv{cats.Env("de"), param}
 	return layla.Execute(env, f)
 }
 
@@ -8,18 +8,13 @@ import (
 	"github.com/mb0/xelf/exp"
 	"github.com/mb0/xelf/lit"
 	"github.com/mb0/xelf/prx"
-	"github.com/mb0/xelf/std"
 	"github.com/mb0/xelf/typ"
 	"github.com/mb0/xelf/utl"
 )
 
-// Env is the default resolver environment for layla.
-var Env = exp.Builtin{
-	NodeLookup,
-	utl.StrLib.Lookup(),
-	utl.TimeLib.Lookup(),
-	std.Core, std.Decl,
-}
+// Env is the default resolver environment for layla using the english locale.
+// Use LocaleEnv to select another locale for a render.
+var Env = LocaleEnv("en")
 
 // ExecuteString parses and executes the expression string s and returns a node or error.
 func ExecuteString(env exp.Env, s string) (*Node, error) {
@@ -40,7 +35,21 @@ func Execute(env exp.Env, rr io.Reader) (*Node, error) {
 	if n == nil {
 		return nil, cor.Errorf("expected *layla.Node got %T", r)
 	}
-	return n, nil
+	return rootNode(n), nil
+}
+
+// alignInherit is the alignment of resolved nodes without align attribute until they are added
+// to a parent. It distinguishes inherited from explicit left alignment.
+const alignInherit = -1
+
+// rootNode returns the resolved root node n with the default alignment, if not set.
+func rootNode(n *Node) *Node {
+	if n.Align == alignInherit {
+		n.Align = AlignLeft
+	} else {
+		n.alignSet = true
+	}
+	return n
 }
 
 // NodeLookup is the resolver lookup for layla node resolvers
@@ -53,23 +62,25 @@ func NodeLookup(sym string) *exp.Spec {
 
 var forms map[string]*exp.Spec
 
+// dataNodes are the node kinds with data instead of child nodes.
+var dataNodes = []string{"line", "text", "markup", "qrcode", "barcode", "style"}
+
 func init() {
 	t, err := prx.Reflect((*Node)(nil))
 	if err != nil {
 		panic(err)
 	}
 	nodeSig := []typ.Param{{Name: "tags?"}, {Name: "tail?"}, {Type: t}}
-	listNodes := []string{"stage", "rect", "ellipse", "box", "vbox", "hbox", "table",
-		"page", "extra", "cover", "header", "footer"}
-	dataNodes := []string{"line", "text", "markup", "qrcode", "barcode"}
+	listNodes := []string{"stage", "rect", "ellipse", "box", "vbox", "hbox", "table", "columns",
+		"page", "extra", "cover", "header", "footer", "footnote", "section", "toc", "styles"}
 	forms = make(map[string]*exp.Spec, len(listNodes)+len(dataNodes))
 	for _, n := range listNodes {
 		forms[n] = &exp.Spec{typ.Form(n, nodeSig),
-			utl.NewNodeResolver(listRules, &Node{Kind: n})}
+			srcResolver{utl.NewNodeResolver(listRules, newProto(n)), n}}
 	}
 	for _, n := range dataNodes {
 		forms[n] = &exp.Spec{typ.Form(n, nodeSig),
-			utl.NewNodeResolver(dataRules, &Node{Kind: n})}
+			srcResolver{utl.NewNodeResolver(dataRules, newProto(n)), n}}
 	}
 }
 
@@ -94,21 +105,45 @@ var listRules = utl.NodeRules{
 					continue
 				}
 				if c == nil {
-					return cor.Errorf("not a layla node %T", el)
-				}
-				if c.Align == 0 {
-					c.Align = o.Align
+					return cor.Errorf("%s child %d is not a layla node %T", o.Kind, len(o.List), el)
 				}
-				if c.Font == nil {
-					c.Font = o.Font
-				}
-				o.List = append(o.List, c)
+				addChild(o, c)
 			}
 			return nil
 		},
 	},
 }
 
+// newProto returns the prototype node of resolved nodes of kind.
+func newProto(kind string) *Node {
+	return &Node{Kind: kind, NodeLayout: NodeLayout{Align: alignInherit}}
+}
+
+// addChild appends the child c to the list of node o. Children inherit the alignment, font and
+// link of o, unless they set their own or are style definitions. Explicit alignments are marked
+// so that ApplyStyles does not replace them with the styled alignment of o.
+func addChild(o, c *Node) {
+	style := c.Kind == "style" || c.Kind == "styles"
+	if c.Align == alignInherit {
+		c.Align = AlignLeft
+		// the parent alignment is not yet inherited itself if unset
+		if !style && o.Align != alignInherit {
+			c.Align = o.Align
+		}
+	} else {
+		c.alignSet = true
+	}
+	if !style {
+		if c.Font == nil {
+			c.Font = o.Font
+		}
+		if c.Link == "" {
+			c.Link = o.Link
+		}
+	}
+	o.List = append(o.List, c)
+}
+
 func getNode(e lit.Lit) *Node {
 	if a, ok := e.(utl.Node); ok {
 		n, _ := a.Ptr().(*Node)
@@ -0,0 +1,134 @@
+package layla
+
+import (
+	"github.com/mb0/xelf/cor"
+)
+
+// NormRot returns the rotation rot normalized to 0, 90, 180 or 270 degrees or an error.
+func NormRot(rot int) (int, error) {
+	rot %= 360
+	if rot < 0 {
+		rot += 360
+	}
+	if rot%90 != 0 {
+		return 0, cor.Errorf("rotation must be a multiple of 90 got %d", rot)
+	}
+	return rot, nil
+}
+
+// Rot is a clockwise rotation by a multiple of 90 degrees inside an area of dimension Dim.
+type Rot struct {
+	Deg int
+	Dim
+}
+
+// RotDim returns the dimension d rotated by rot degrees.
+func RotDim(d Dim, rot int) Dim {
+	if rot == 90 || rot == 270 {
+		d.W, d.H = d.H, d.W
+	}
+	return d
+}
+
+// Area returns the dimension of the rotated area.
+func (r Rot) Area() Dim { return RotDim(r.Dim, r.Deg) }
+
+// Pos returns the rotated position of point p.
+func (r Rot) Pos(p Pos) Pos {
+	switch r.Deg {
+	case 90:
+		return Pos{r.H - p.Y, p.X}
+	case 180:
+		return Pos{r.W - p.X, r.H - p.Y}
+	case 270:
+		return Pos{p.Y, r.W - p.X}
+	}
+	return p
+}
+
+// Box returns the rotated box b.
+func (r Rot) Box(b Box) Box {
+	p := r.Pos(b.Pos)
+	q := r.Pos(Pos{b.X + b.W, b.Y + b.H})
+	return Box{Pos{fmin(p.X, q.X), fmin(p.Y, q.Y)}, RotDim(b.Dim, r.Deg)}
+}
+
+// Node rotates the display node d in place. Lines are rotated by their end points and all
+// other nodes by their box. The node rotation is increased to indicate the content orientation.
+func (r Rot) Node(d *Node) {
+	switch d.Kind {
+	case "page":
+		return
+	case "line":
+		p := r.Pos(d.Pos)
+		q := r.Pos(Pos{d.X + d.W, d.Y + d.H})
+		d.Box = Box{p, Dim{q.X - p.X, q.Y - p.Y}}
+	default:
+		d.Box = r.Box(d.Box)
+	}
+	d.Rot = (d.Rot + r.Deg) % 360
+}
+
+// local holds the unrotated content box of a node rotated during layout.
+type local struct {
+	Box
+	Deg int
+}
+
+// place transforms the display node d from the local space to the calculated box of the
+// rotated node at position p.
+func (l *local) place(d *Node, p Pos) {
+	d.X -= l.X
+	d.Y -= l.Y
+	Rot{l.Deg, l.Dim}.Node(d)
+	d.X += p.X
+	d.Y += p.Y
+}
+
+// Rotate rotates all nodes in the display list draw of a stage with dimension dim by rot degrees
+// clockwise. It returns the rotated stage dimension or an error.
+func Rotate(draw []*Node, dim Dim, rot int) (Dim, error) {
+	rot, err := NormRot(rot)
+	if err != nil {
+		return dim, err
+	}
+	if rot == 0 {
+		return dim, nil
+	}
+	r := Rot{rot, dim}
+	for _, d := range draw {
+		r.Node(d)
+	}
+	return r.Area(), nil
+}
+
+// Unrot returns the unrotated content box of a display node with box b and rotation rot.
+// The content box has the same center as b and should be drawn rotated by rot degrees.
+func Unrot(b Box, rot int) Box {
+	c := RotDim(b.Dim, rot)
+	b.X += (b.W - c.W) / 2
+	b.Y += (b.H - c.H) / 2
+	b.Dim = c
+	return b
+}
+
+// Anchor returns the position of the top left corner of the unrotated content in a display node
+// box b with rotation rot. Printer commands usually rotate their content around this point.
+func Anchor(b Box, rot int) Pos {
+	switch rot {
+	case 90:
+		return Pos{b.X + b.W, b.Y}
+	case 180:
+		return Pos{b.X + b.W, b.Y + b.H}
+	case 270:
+		return Pos{b.X, b.Y + b.H}
+	}
+	return b.Pos
+}
+
+func fmin(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
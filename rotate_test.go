@@ -0,0 +1,104 @@
+package layla
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestRotate(t *testing.T) {
+	dim := Dim{400, 200}
+	draw := func() []*Node {
+		return []*Node{
+			{Kind: "rect", Box: Box{Pos{100, 20}, Dim{60, 40}}},
+			{Kind: "line", Box: Box{Pos{10, 20}, Dim{100, -10}}},
+			{Kind: "page"},
+			{Kind: "text", Box: Box{Pos{0, 0}, Dim{80, 40}}, Data: "Hello"},
+		}
+	}
+	tests := []struct {
+		rot  int
+		dim  string
+		want string
+	}{
+		{0, `{"w":400,"h":200}`, "" +
+			"rect 100 20 60 40 0\n" +
+			"line 10 20 100 -10 0\n" +
+			"page 0 0 0 0 0\n" +
+			"text 0 0 80 40 0\n"},
+		{90, `{"w":200,"h":400}`, "" +
+			"rect 140 100 40 60 90\n" +
+			"line 180 10 10 100 90\n" +
+			"page 0 0 0 0 0\n" +
+			"text 160 0 40 80 90\n"},
+		{180, `{"w":400,"h":200}`, "" +
+			"rect 240 140 60 40 180\n" +
+			"line 390 180 -100 10 180\n" +
+			"page 0 0 0 0 0\n" +
+			"text 320 160 80 40 180\n"},
+		{-90, `{"w":200,"h":400}`, "" +
+			"rect 20 240 40 60 270\n" +
+			"line 20 390 -10 -100 270\n" +
+			"page 0 0 0 0 0\n" +
+			"text 0 320 40 80 270\n"},
+	}
+	for _, test := range tests {
+		ns := draw()
+		got, err := Rotate(ns, dim, test.rot)
+		if err != nil {
+			t.Errorf("rotate %d error: %v", test.rot, err)
+			continue
+		}
+		b, _ := json.Marshal(got)
+		if string(b) != test.dim {
+			t.Errorf("rotate %d want dim %s got %s", test.rot, test.dim, b)
+		}
+		var res string
+		for _, n := range ns {
+			res += fmt.Sprintf("%s %g %g %g %g %d\n", n.Kind, n.X, n.Y, n.W, n.H, n.Rot)
+		}
+		if res != test.want {
+			t.Errorf("rotate %d\nwant %s\n got %s", test.rot, test.want, res)
+		}
+	}
+	if _, err := Rotate(draw(), dim, 45); err == nil {
+		t.Errorf("want error for rotation 45")
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	b := Box{Pos{160, 0}, Dim{40, 80}}
+	tests := []struct {
+		rot  int
+		want Pos
+	}{
+		{0, Pos{160, 0}},
+		{90, Pos{200, 0}},
+		{180, Pos{200, 80}},
+		{270, Pos{160, 80}},
+	}
+	for _, test := range tests {
+		if got := Anchor(b, test.rot); got != test.want {
+			t.Errorf("anchor %d want %v got %v", test.rot, test.want, got)
+		}
+	}
+	if got := Unrot(b, 90); got != (Box{Pos{140, 20}, Dim{80, 40}}) {
+		t.Errorf("unrot want centered box got %v", got)
+	}
+}
+
+func TestNodeRot(t *testing.T) {
+	man := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatalf("register font error: %v", err)
+	}
+	lay := &Layouter{Manager: man, Spacer: 'i', Styler: FakeBoldStyler}
+	n := &Node{Kind: "stage", Box: Box{Dim: Dim{360, 0}}, List: []*Node{
+		{Kind: "text", NodeLayout: NodeLayout{Rot: 90}, Data: "Hello"},
+	}}
+	if _, err := lay.LayoutAndPage(n); err == nil {
+		t.Errorf("want error for rotated node without height")
+	}
+}
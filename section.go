@@ -0,0 +1,145 @@
+package layla
+
+import "github.com/mb0/xelf/cor"
+
+// section holds the extra, header and footer nodes and the page numbering of the root page node
+// or a section node in a page document.
+//
+// Sections are usually children of the body vbox. They start on a new page and switch the
+// extra, header and footer nodes for pages starting in the section. Content after a section
+// starts on a new page of the enclosing section. The on attribute of those
+// nodes selects the 'first' page of the section, the 'odd' or 'even' pages of the document, and
+// nodes without it are used for all other pages. Section nodes override the nodes of the
+// enclosing section with the same kind and page selection. Sections with the restart
+// attribute restart the section page numbers, otherwise they continue those of the enclosing
+// section. Texts in extra, header and footer nodes can use the placeholders µP and µT for the
+// document page number and count as well as the fields µ{spage} and µ{spages} for the section
+// page number and count.
+type section struct {
+	Node              *Node
+	extra, head, foot []*Node
+	// first is the index of the first page and start the first page of the numbering.
+	first, start int
+}
+
+// newSection returns the section of node n, enclosed by section p.
+func newSection(n *Node, p *section) (*section, error) {
+	s := &section{Node: n}
+	for _, e := range n.List {
+		var list *[]*Node
+		switch e.Kind {
+		case "extra":
+			list = &s.extra
+		case "header":
+			list = &s.head
+		case "footer":
+			list = &s.foot
+		default:
+			continue
+		}
+		switch e.On {
+		case "", "first", "odd", "even":
+		default:
+			return nil, nodeErr(e, nil, cor.Errorf("unknown page selector %q", e.On))
+		}
+		*list = append(*list, e)
+	}
+	if p != nil {
+		s.extra = inherit(s.extra, p.extra)
+		s.head = inherit(s.head, p.head)
+		s.foot = inherit(s.foot, p.foot)
+		s.start = p.start
+	}
+	return s, nil
+}
+
+// inherit returns list with the nodes of outer added, that select pages not selected by list.
+func inherit(list, outer []*Node) []*Node {
+	res := list
+outer:
+	for _, o := range outer {
+		for _, e := range list {
+			if e.On == o.On {
+				continue outer
+			}
+		}
+		res = append(res, o)
+	}
+	return res
+}
+
+// pick returns the node of list for the page with index i. Nodes for the first page of the
+// section are preferred over nodes for odd or even pages and those over nodes for all pages.
+func (s *section) pick(list []*Node, i int) *Node {
+	var par, all *Node
+	for _, e := range list {
+		switch e.On {
+		case "first":
+			if i == s.first {
+				return e
+			}
+		case "odd", "even":
+			if (e.On == "odd") == (i%2 == 0) {
+				par = e
+			}
+		default:
+			all = e
+		}
+	}
+	if par != nil {
+		return par
+	}
+	return all
+}
+
+// collectSection starts a new page for the section node n and collects its content. The last
+// page is replaced if it is still empty. Following content starts on a new page.
+func (p *pager) collectSection(n *Node) error {
+	prev := p.sec
+	s, err := newSection(n, prev)
+	if err != nil {
+		return err
+	}
+	p.sec = s
+	defer func() { p.sec = prev }()
+	org := n.Calc.Y
+	if x := p.list[len(p.list)-1]; x.used() == 0 && len(x.notes) == 0 {
+		p.list = p.list[:len(p.list)-1]
+		org = x.Org
+	}
+	s.first = len(p.list)
+	if n.Restart {
+		s.start = s.first
+	}
+	p.newPage(org)
+	if p.paths != nil {
+		for _, d := range debugNodes(n, p.paths[n]) {
+			p.draw(d, nil, nil)
+		}
+	}
+	err = p.collectAll(n.List)
+	p.after = n.Calc.Y + n.Calc.H
+	return err
+}
+
+// sectionLayout lays out the extra, header and footer nodes of section n like those of the root
+// page and the other child nodes like a vbox.
+func (l *Layouter) sectionLayout(n *Node, stack []*Node) error {
+	root := stack[0]
+	a := root.Pad.Inset(root.Calc)
+	list := n.List
+	defer func() { n.List = list }()
+	n.List = make([]*Node, 0, len(list))
+	for _, e := range list {
+		switch e.Kind {
+		case "extra", "header", "footer":
+			_, err := l.layout(e, a, append(stack, n))
+			if err != nil {
+				return err
+			}
+		default:
+			n.List = append(n.List, e)
+		}
+	}
+	return l.vboxLayout(n, stack)
+}
@@ -0,0 +1,68 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestSections(t *testing.T) {
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`(page w:200 h:100 font.line:10
+			(header on:'odd' (rect w:1 h:10))
+			(header on:'even' (rect w:2 h:10))
+			(footer (text 'µP/µT µ{spage}/µ{spages}'))
+			(vbox
+				(rect h:70)
+				(section restart:true
+					(header on:'first' (rect w:4 h:20))
+					(rect h:70)
+					(rect h:70)
+					(rect h:70))
+				(rect h:30)))`, []string{
+			"rect 0 1 10",
+			"text 90 191 10 1/5 1/5",
+			"rect 10 200 70",
+			"page 0 0 0",
+			"rect 0 4 20",
+			"text 90 191 10 2/5 1/3",
+			"rect 20 200 70",
+			"page 0 0 0",
+			"rect 0 1 10",
+			"text 90 191 10 3/5 2/3",
+			"rect 10 200 70",
+			"page 0 0 0",
+			"rect 0 2 10",
+			"text 90 191 10 4/5 3/3",
+			"rect 10 200 70",
+			// content after the section starts on a new page of the enclosing section
+			"page 0 0 0",
+			"rect 0 1 10",
+			"text 90 191 10 5/5 5/5",
+			"rect 10 200 30",
+		}},
+	}
+	for _, test := range tests {
+		_, got, err := pageSource(m, test.raw, func(d *Node) string {
+			return strings.TrimSpace(fmt.Sprintf("%s %g %g %g %s", d.Kind, d.Y, d.W, d.H, d.Data))
+		})
+		if err != nil {
+			t.Errorf("for %s error: %v", test.raw, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("for %s\nwant display list:\n%s\ngot:\n%s", test.raw,
+				strings.Join(test.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+	_, _, err := pageSource(m, `(page w:200 h:100 (header on:'left') (rect h:10))`, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown page selector "left"`) {
+		t.Errorf("want selector error got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package layla
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mb0/xelf/exp"
+	"github.com/mb0/xelf/typ"
+)
+
+// Src is the template source position of a node.
+type Src struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (s Src) String() string {
+	if s.Line == 0 {
+		return s.File
+	}
+	if s.File == "" {
+		return fmt.Sprintf("%d:%d", s.Line, s.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Line, s.Col)
+}
+
+// NodeError is an error with the source position and path of the node that caused it.
+type NodeError struct {
+	Src  Src
+	Path string
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	if s := e.Src.String(); s != "" {
+		return fmt.Sprintf("%s: %s: %v", s, e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *NodeError) Unwrap() error { return e.Err }
+
+// nodeErr returns err as node error for node n with the ancestors in stack. Errors that already
+// are node errors are returned as is.
+func nodeErr(n *Node, stack []*Node, err error) error {
+	var ne *NodeError
+	if err == nil || errors.As(err, &ne) {
+		return err
+	}
+	return &NodeError{Src: n.Src, Path: NodePath(n, stack), Err: err}
+}
+
+// NodePath returns a path for node n with the ancestors in stack like stage/vbox[2]/text.
+// The one-based index is added to nodes with siblings of the same kind.
+func NodePath(n *Node, stack []*Node) string {
+	var b strings.Builder
+	for i, p := range stack {
+		if i > 0 {
+			b.WriteByte('/')
+		}
+		b.WriteString(p.Kind)
+		if i > 0 {
+			writeIndex(&b, stack[i-1], p)
+		}
+		if i == len(stack)-1 {
+			b.WriteByte('/')
+		}
+	}
+	b.WriteString(n.Kind)
+	if len(stack) > 0 {
+		writeIndex(&b, stack[len(stack)-1], n)
+	}
+	return b.String()
+}
+
+func writeIndex(b *strings.Builder, p, n *Node) {
+	var idx, cnt int
+	for _, e := range p.List {
+		if e.Kind == n.Kind {
+			cnt++
+			if e == n {
+				idx = cnt
+			}
+		}
+	}
+	if cnt > 1 && idx > 0 {
+		fmt.Fprintf(b, "[%d]", idx)
+	}
+}
+
+// fileEnv is the resolver environment of a template file. Node resolvers use the innermost file
+// environment for the file name of the source position.
+type fileEnv struct {
+	exp.Env
+	file string
+}
+
+func (e *fileEnv) Parent() exp.Env          { return e.Env }
+func (e *fileEnv) Supports(byte) bool       { return false }
+func (e *fileEnv) Get(sym string) *exp.Spec { return nil }
+
+// envFile returns the template file name of the environment env or an empty string.
+func envFile(env exp.Env) string {
+	for env != nil {
+		if f, ok := env.(*fileEnv); ok {
+			return f.file
+		}
+		env = env.Parent()
+	}
+	return ""
+}
+
+// srcResolver wraps a node resolver and sets the source position of the call to resolved nodes.
+// The file name is that of the template the call is read from.
+type srcResolver struct {
+	exp.Resolver
+	kind string
+}
+
+func (r srcResolver) Resolve(c *exp.Ctx, env exp.Env, x *exp.Call, hint typ.Type) (exp.El, error) {
+	s := x.Source()
+	src := Src{File: envFile(env), Line: int(s.Line), Col: int(s.Col)}
+	res, err := r.Resolver.Resolve(c, env, x, hint)
+	if err != nil {
+		if err == exp.ErrUnres {
+			return res, err
+		}
+		var ne *NodeError
+		if errors.As(err, &ne) {
+			return nil, err
+		}
+		return nil, &NodeError{Src: src, Path: r.kind, Err: err}
+	}
+	if a, ok := res.(*exp.Atom); ok {
+		if n := getNode(a.Lit); n != nil {
+			n.Src = src
+		}
+	}
+	return res, nil
+}
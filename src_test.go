@@ -0,0 +1,41 @@
+package layla
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNodeErrors(t *testing.T) {
+	bad := &Node{Kind: "hbox", NodeLayout: NodeLayout{Rot: 45}, Src: Src{Line: 4, Col: 3}}
+	root := &Node{Kind: "stage", Box: Box{Dim: Dim{W: 100}}, Src: Src{File: "a.layla", Line: 1, Col: 1},
+		List: []*Node{
+			{Kind: "vbox"},
+			{Kind: "line"},
+			{Kind: "vbox", List: []*Node{bad}},
+		}}
+	if got := NodePath(bad, []*Node{root, root.List[2]}); got != "stage/vbox[2]/hbox" {
+		t.Errorf("want path stage/vbox[2]/hbox got %s", got)
+	}
+	if got := NodePath(root.List[1], []*Node{root}); got != "stage/line" {
+		t.Errorf("want path stage/line got %s", got)
+	}
+	l := &Layouter{}
+	err := l.Layout(root)
+	var ne *NodeError
+	if !errors.As(err, &ne) {
+		t.Fatalf("want node error got %v", err)
+	}
+	want := "4:3: stage/vbox[2]/hbox: hbox can only be rotated in 90 degree steps"
+	if err.Error() != want {
+		t.Errorf("want error %q got %q", want, err)
+	}
+	root.List[0].Style = "missing"
+	err = ApplyStyles(root)
+	want = "stage/vbox[1]: unknown style \"missing\""
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q got %v", want, err)
+	}
+	if s := (Src{File: "a.layla", Line: 2, Col: 7}).String(); s != "a.layla:2:7" {
+		t.Errorf("want src a.layla:2:7 got %s", s)
+	}
+}
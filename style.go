@@ -0,0 +1,211 @@
+package layla
+
+import (
+	"strings"
+
+	"github.com/mb0/xelf/cor"
+)
+
+// ApplyStyles resolves the named styles referenced by the style attribute of node n and its
+// descendants and removes all style definitions from the tree.
+//
+// Styles are defined by style nodes with the name as data, optionally grouped in styles nodes,
+// and bundle font, align, padding, margin, border, color and page break controls:
+//
+//	(style 'title' font:{name:'bold' size:12} align:2)
+//
+// Definitions apply to the subtree of the node they are defined in and override definitions
+// of the same name from outer nodes and the shared sheets. A node can reference multiple
+// styles separated by spaces, later styles override earlier ones. Explicitly set node
+// attributes override styles. Fonts and page break controls are merged by field, anchors are
+// not applied from styles. Changed fonts and alignment of a styled node are inherited by
+// children that did not set their own.
+func ApplyStyles(n *Node, sheets ...*Node) error {
+	defs, err := addStyles(nil, sheets)
+	if err != nil {
+		return err
+	}
+	return applyStyles(n, defs, nil, nil, 0, 0, nil)
+}
+
+// addStyles returns a copy of defs with the style definitions in list added.
+func addStyles(defs map[string]*Node, list []*Node) (map[string]*Node, error) {
+	res := make(map[string]*Node, len(defs)+len(list))
+	for k, v := range defs {
+		res[k] = v
+	}
+	var add func([]*Node) error
+	add = func(list []*Node) error {
+		for _, d := range list {
+			switch d.Kind {
+			case "style":
+				if d.Data == "" {
+					return cor.Errorf("style definition without name")
+				}
+				res[d.Data] = d
+			case "styles":
+				err := add(d.List)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return res, add(list)
+}
+
+// applyStyles applies the styles to n and its descendants. The original font and alignment
+// of the parent of and oa detect values copied from the parent during construction, those are
+// replaced with the styled parent values pf and pa. Alignments marked as explicit by the
+// resolver are kept. Stack holds the ancestors of n.
+func applyStyles(n *Node, defs map[string]*Node, of, pf *Font, oa, pa int, stack []*Node) (err error) {
+	var local bool
+	for _, e := range n.List {
+		if e.Kind == "style" || e.Kind == "styles" {
+			local = true
+			break
+		}
+	}
+	if local {
+		defs, err = addStyles(defs, n.List)
+		if err != nil {
+			return nodeErr(n, stack, err)
+		}
+		list := n.List[:0]
+		for _, e := range n.List {
+			if e.Kind != "style" && e.Kind != "styles" {
+				list = append(list, e)
+			}
+		}
+		n.List = list
+	}
+	nf, na := n.Font, n.Align
+	var st Node
+	for _, name := range strings.Fields(n.Style) {
+		d := defs[name]
+		if d == nil {
+			return nodeErr(n, stack, cor.Errorf("unknown style %q", name))
+		}
+		mergeStyle(&st, d)
+	}
+	n.Style = ""
+	if nf != nil && nf != of {
+		if st.Font != nil {
+			f := *st.Font
+			mergeFont(&f, nf)
+			n.Font = &f
+		}
+	} else if st.Font != nil {
+		f := *st.Font
+		n.Font = &f
+	} else {
+		n.Font = pf
+	}
+	if !n.alignSet && na == oa {
+		n.Align = pa
+		if st.Align != 0 || st.alignSet {
+			n.Align = st.Align
+		}
+	}
+	if n.Pad == nil {
+		n.Pad = st.Pad
+	}
+	if n.Mar == nil {
+		n.Mar = st.Mar
+	}
+	if n.Border == (Border{}) {
+		n.Border = st.Border
+	}
+	if n.Color == nil {
+		n.Color = st.Color
+	}
+	pg := n.Paging
+	n.Paging = st.Paging
+	n.Anchor = pg.Anchor
+	mergePaging(&n.Paging, &pg)
+	stack = append(stack, n)
+	for _, e := range n.List {
+		err = applyStyles(e, defs, nf, n.Font, na, n.Align, stack)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeStyle sets the font, align, padding, margin, border, color and paging of src to dst if set.
+func mergeStyle(dst, src *Node) {
+	if src.Font != nil {
+		f := Font{}
+		if dst.Font != nil {
+			f = *dst.Font
+		}
+		mergeFont(&f, src.Font)
+		dst.Font = &f
+	}
+	if src.Align != 0 || src.alignSet {
+		dst.Align, dst.alignSet = src.Align, true
+	}
+	if src.Pad != nil {
+		dst.Pad = src.Pad
+	}
+	if src.Mar != nil {
+		dst.Mar = src.Mar
+	}
+	if src.Border != (Border{}) {
+		dst.Border = src.Border
+	}
+	if src.Color != nil {
+		dst.Color = src.Color
+	}
+	mergePaging(&dst.Paging, &src.Paging)
+}
+
+// mergePaging sets the page break controls of src to dst if set. The anchor is not merged,
+// because it names a single node.
+func mergePaging(dst, src *Paging) {
+	if src.Keep {
+		dst.Keep = true
+	}
+	if src.Next {
+		dst.Next = true
+	}
+	if src.Break != "" {
+		dst.Break = src.Break
+	}
+	if src.Widows != 0 {
+		dst.Widows = src.Widows
+	}
+	if src.Orphans != 0 {
+		dst.Orphans = src.Orphans
+	}
+	if src.On != "" {
+		dst.On = src.On
+	}
+	if src.Restart {
+		dst.Restart = true
+	}
+	if src.Toc != 0 {
+		dst.Toc = src.Toc
+	}
+	if src.Span {
+		dst.Span = true
+	}
+	if src.Balance {
+		dst.Balance = true
+	}
+}
+
+// mergeFont sets the name, size and line height of src to dst if set.
+func mergeFont(dst, src *Font) {
+	if src.Name != "" {
+		dst.Name = src.Name
+	}
+	if src.Size != 0 {
+		dst.Size = src.Size
+	}
+	if src.Line != 0 {
+		dst.Line = src.Line
+	}
+}
@@ -0,0 +1,126 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestApplyStyles(t *testing.T) {
+	sheet := &Node{Kind: "styles", List: []*Node{
+		{Kind: "style", Data: "body", Font: &Font{Name: "regular", Size: 8}},
+		{Kind: "style", Data: "title", Font: &Font{Name: "bold", Size: 12},
+			NodeLayout: NodeLayout{Align: AlignCenter}},
+		{Kind: "style", Data: "boxed", Border: Border{W: 2}, Color: &Color{R: 255}},
+	}}
+	// fonts and alignment are copied from parents during construction
+	stage := &Node{Kind: "stage", Style: "body"}
+	box := &Node{Kind: "vbox", Style: "title", Font: stage.Font}
+	local := &Node{Kind: "style", Data: "boxed", Border: Border{W: 1}}
+	stage.List = []*Node{
+		box,
+		{Kind: "text", Data: "body", Font: stage.Font},
+		{Kind: "text", Data: "size", Font: &Font{Size: 10}, Style: "title boxed"},
+	}
+	box.List = []*Node{
+		local,
+		{Kind: "text", Data: "inherit", Font: box.Font},
+		{Kind: "text", Data: "left", Style: "boxed", Font: box.Font,
+			NodeLayout: NodeLayout{Align: AlignRight}},
+	}
+	err := ApplyStyles(stage, sheet)
+	if err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	// apply again to check it is idempotent
+	err = ApplyStyles(stage)
+	if err != nil {
+		t.Fatalf("apply again error: %v", err)
+	}
+	var b strings.Builder
+	var walk func(*Node)
+	walk = func(n *Node) {
+		fmt.Fprintf(&b, "%s %s %s %g %d %g %v\n", n.Kind, n.Data, n.Font.Name, n.Font.Size,
+			n.Align, n.Border.W, n.Color)
+		for _, e := range n.List {
+			walk(e)
+		}
+	}
+	walk(stage)
+	want := "" +
+		"stage  regular 8 0 0 <nil>\n" +
+		"vbox  bold 12 2 0 <nil>\n" +
+		"text inherit bold 12 2 0 <nil>\n" +
+		"text left bold 12 1 1 <nil>\n" +
+		"text body regular 8 0 0 <nil>\n" +
+		"text size bold 10 2 2 &{255 0 0}\n"
+	if got := b.String(); got != want {
+		t.Errorf("want:\n%sgot:\n%s", want, got)
+	}
+	// explicit alignments of resolved nodes override the styled alignment of the parent
+	vbox := newProto("vbox")
+	vbox.Style = "title"
+	left, inherit := newProto("text"), newProto("text")
+	left.Align = AlignLeft
+	addChild(vbox, left)
+	addChild(vbox, inherit)
+	err = ApplyStyles(rootNode(vbox), sheet)
+	if err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if left.Align != AlignLeft || inherit.Align != AlignCenter {
+		t.Errorf("want explicit left and inherited center got %d %d", left.Align, inherit.Align)
+	}
+	err = ApplyStyles(&Node{Kind: "text", Style: "missing"})
+	if err == nil || !strings.Contains(err.Error(), `unknown style "missing"`) {
+		t.Errorf("want unknown style error got %v", err)
+	}
+}
+
+func TestStyleDefInherit(t *testing.T) {
+	// style definitions do not inherit from the node they are defined in
+	vbox := &Node{Kind: "vbox", Font: &Font{Size: 8}, NodeLayout: NodeLayout{Align: AlignCenter}}
+	box := &Node{Kind: "box", Font: &Font{Size: 12}}
+	text := &Node{Kind: "text", Style: "pad", Data: "x"}
+	addChild(vbox, &Node{Kind: "style", Data: "pad", NodeLayout: NodeLayout{Pad: &Off{L: 2}}})
+	addChild(box, text)
+	addChild(vbox, box)
+	err := ApplyStyles(vbox)
+	if err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if text.Font.Size != 12 || text.Align != 0 || text.Pad == nil {
+		t.Errorf("want text with size 12, no align and padding got %v %d %v",
+			text.Font, text.Align, text.Pad)
+	}
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	sheet := &Node{Kind: "styles", List: []*Node{{Kind: "style", Data: "pad", NodeLayout: NodeLayout{Pad: &Off{L: 4}}}}}
+	lay := NewLayouter(m)
+	lay.Styles = []*Node{sheet}
+	text = &Node{Kind: "text", Style: "pad", Data: "x"}
+	err = lay.Layout(&Node{Kind: "stage", Box: Box{Dim: Dim{W: 100, H: 100}}, List: []*Node{text}})
+	if err != nil || text.Pad == nil || text.Pad.L != 4 {
+		t.Errorf("want shared style applied got %v %v", text.Pad, err)
+	}
+}
+
+func TestStylePaging(t *testing.T) {
+	sheet := &Node{Kind: "styles", List: []*Node{
+		{Kind: "style", Data: "head", Paging: Paging{Keep: true, Next: true, Anchor: "head"}},
+		{Kind: "style", Data: "toc", Paging: Paging{Toc: 2, Widows: 3}},
+	}}
+	a := &Node{Kind: "text", Style: "head toc", Paging: Paging{Widows: 2}}
+	b := &Node{Kind: "text", Style: "head", Paging: Paging{Anchor: "b", Break: "before"}}
+	err := ApplyStyles(&Node{Kind: "page", List: []*Node{a, b}}, sheet)
+	if err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if want := (Paging{Keep: true, Next: true, Toc: 2, Widows: 2}); a.Paging != want {
+		t.Errorf("want paging %+v got %+v", want, a.Paging)
+	}
+	if want := (Paging{Keep: true, Next: true, Break: "before", Anchor: "b"}); b.Paging != want {
+		t.Errorf("want paging %+v got %+v", want, b.Paging)
+	}
+}
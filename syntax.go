@@ -0,0 +1,344 @@
+package layla
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mb0/xelf/cor"
+)
+
+// Tree is an unevaluated syntax tree of layla source used to format and lint templates.
+type Tree struct {
+	// Tok is '(', '[' or '{' for groups, ':' for tags and zero for atoms.
+	Tok byte
+	// Raw is the atom text or tag name.
+	Raw string
+	// Seq holds the group elements or the optional tag value.
+	Seq []*Tree
+	Src Src
+}
+
+// head returns the symbol of a call or an empty string.
+func (t *Tree) head() string {
+	if t.Tok == '(' && len(t.Seq) > 0 && t.Seq[0].Tok == 0 {
+		return t.Seq[0].Raw
+	}
+	return ""
+}
+
+// isNode returns whether t is a call of a registered node form.
+func (t *Tree) isNode() bool { return forms[t.head()] != nil }
+
+// ParseTrees parses all expressions in src of the named file and returns the syntax trees.
+// Commas are treated as whitespace.
+func ParseTrees(src []byte, file string) ([]*Tree, error) {
+	p := &parser{src: src, file: file, line: 1, col: 1}
+	var res []*Tree
+	for {
+		t, err := p.tree()
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			break
+		}
+		res = append(res, t)
+	}
+	if p.off < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.off])
+	}
+	return res, nil
+}
+
+type parser struct {
+	src       []byte
+	file      string
+	off       int
+	line, col int
+}
+
+func (p *parser) pos() Src { return Src{p.file, p.line, p.col} }
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return cor.Errorf("%s: %s", p.pos(), cor.Errorf(format, args...))
+}
+
+func (p *parser) next() byte {
+	c := p.src[p.off]
+	p.off++
+	if c == '\n' {
+		p.line++
+		p.col = 1
+	} else if c < 0x80 || c >= 0xc0 {
+		p.col++
+	}
+	return c
+}
+
+func (p *parser) skip() {
+	for p.off < len(p.src) {
+		switch p.src[p.off] {
+		case ' ', '\t', '\n', '\r', ',':
+			p.next()
+		default:
+			return
+		}
+	}
+}
+
+// tree returns the next tree, nil at a closing bracket or the end of input, or an error.
+func (p *parser) tree() (*Tree, error) {
+	p.skip()
+	if p.off >= len(p.src) {
+		return nil, nil
+	}
+	src := p.pos()
+	switch c := p.src[p.off]; c {
+	case ')', ']', '}':
+		return nil, nil
+	case '(', '[', '{':
+		p.next()
+		t := &Tree{Tok: c, Src: src}
+		for {
+			e, err := p.tree()
+			if err != nil {
+				return nil, err
+			}
+			if e == nil {
+				break
+			}
+			t.Seq = append(t.Seq, e)
+		}
+		end := map[byte]byte{'(': ')', '[': ']', '{': '}'}[c]
+		if p.off >= len(p.src) {
+			return nil, cor.Errorf("%s: unclosed %c", src, c)
+		}
+		if p.src[p.off] != end {
+			return nil, p.errorf("want %c got %c", end, p.src[p.off])
+		}
+		p.next()
+		return t, nil
+	case '\'', '"', '`':
+		start := p.off
+		p.next()
+		for {
+			if p.off >= len(p.src) {
+				return nil, cor.Errorf("%s: unclosed string", src)
+			}
+			e := p.next()
+			if e == '\\' && c != '`' && p.off < len(p.src) {
+				p.next()
+			} else if e == c {
+				break
+			}
+		}
+		return &Tree{Raw: string(p.src[start:p.off]), Src: src}, nil
+	}
+	start := p.off
+	for p.off < len(p.src) {
+		c := p.src[p.off]
+		if strings.IndexByte(" \t\n\r,()[]{}'\"`", c) >= 0 {
+			break
+		}
+		p.next()
+		if c == ':' && p.off-start > 1 {
+			// tags take the following element as value
+			t := &Tree{Tok: ':', Raw: string(p.src[start : p.off-1]), Src: src}
+			v, err := p.tree()
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				t.Seq = []*Tree{v}
+			}
+			return t, nil
+		}
+	}
+	return &Tree{Raw: string(p.src[start:p.off]), Src: src}, nil
+}
+
+// FormatSource returns the layla source src of the named file canonically formatted.
+//
+// Calls are written on one line unless they contain node forms. Those are written with their
+// head and leading arguments on the first line, each following argument on a separate line
+// indented by a tab, and the closing paren on a separate line. The tags of node forms are moved
+// before other arguments and sorted in the attribute order of the node fields. Positional font
+// lists are written as records.
+func FormatSource(src []byte, file string) ([]byte, error) {
+	ts, err := ParseTrees(src, file)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for i, t := range ts {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		writeTree(&b, t, 0)
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// attrFields maps the node attributes to their valid record fields used with dotted tags and
+// attrOrder is the canonical order of node attributes. Both are derived from the node type.
+var attrFields, attrOrder = nodeAttrs()
+
+// nodeAttrs returns the record fields and order of the node attributes named by the json tags
+// of the node type. Attributes that are no structs or struct pointers have no record fields.
+func nodeAttrs() (map[string][]string, []string) {
+	fields := make(map[string][]string)
+	var order []string
+	var add func(reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := jsonName(f)
+			if f.Anonymous && name == "" {
+				add(f.Type)
+				continue
+			}
+			if name == "" || name == "kind" {
+				continue
+			}
+			order = append(order, name)
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			var sub []string
+			if ft.Kind() == reflect.Struct {
+				for j := 0; j < ft.NumField(); j++ {
+					if n := jsonName(ft.Field(j)); n != "" {
+						sub = append(sub, n)
+					}
+				}
+			}
+			fields[name] = sub
+		}
+	}
+	add(reflect.TypeOf(Node{}))
+	return fields, order
+}
+
+// jsonName returns the json name of the exported struct field f or an empty string.
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	name := f.Tag.Get("json")
+	if i := strings.IndexByte(name, ','); i >= 0 {
+		name = name[:i]
+	}
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func attrIndex(name string) int {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	for i, a := range attrOrder {
+		if a == name {
+			return i
+		}
+	}
+	return len(attrOrder)
+}
+
+// normNode returns the arguments of a node form with sorted tags first and font lists as records.
+func normNode(args []*Tree) []*Tree {
+	var tags, rest []*Tree
+	for _, a := range args {
+		if a.Tok == ':' {
+			if a.Raw == "font" && len(a.Seq) == 1 && a.Seq[0].Tok == '[' {
+				a = &Tree{Tok: ':', Raw: a.Raw, Src: a.Src, Seq: []*Tree{fontRecord(a.Seq[0])}}
+			}
+			tags = append(tags, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return attrIndex(tags[i].Raw) < attrIndex(tags[j].Raw)
+	})
+	return append(tags, rest...)
+}
+
+// fontRecord converts a positional font list [name size line] to a record omitting empty values.
+func fontRecord(l *Tree) *Tree {
+	r := &Tree{Tok: '{', Src: l.Src}
+	for i, e := range l.Seq {
+		if i > 2 {
+			return l
+		}
+		if e.Tok == 0 && (e.Raw == "''" || e.Raw == `""` || e.Raw == "0") {
+			continue
+		}
+		key := []string{"name", "size", "line"}[i]
+		r.Seq = append(r.Seq, &Tree{Tok: ':', Raw: key, Src: e.Src, Seq: []*Tree{e}})
+	}
+	return r
+}
+
+// isBlock returns whether the call t contains node forms.
+func isBlock(t *Tree) bool {
+	if t.Tok == 0 {
+		return false
+	}
+	for _, e := range t.Seq {
+		if e.isNode() || isBlock(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTree(b *bytes.Buffer, t *Tree, depth int) {
+	switch t.Tok {
+	case 0:
+		b.WriteString(t.Raw)
+	case ':':
+		b.WriteString(t.Raw)
+		b.WriteByte(':')
+		if len(t.Seq) > 0 {
+			writeTree(b, t.Seq[0], depth)
+		}
+	case '[', '{':
+		b.WriteByte(t.Tok)
+		for i, e := range t.Seq {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeTree(b, e, depth)
+		}
+		b.WriteByte(t.Tok + 2)
+	case '(':
+		seq := t.Seq
+		if t.isNode() {
+			seq = append([]*Tree{seq[0]}, normNode(seq[1:])...)
+		}
+		b.WriteByte('(')
+		block := isBlock(t)
+		var nl bool
+		for i, e := range seq {
+			if block && (nl || e.isNode() || isBlock(e)) {
+				nl = true
+				b.WriteByte('\n')
+				b.WriteString(strings.Repeat("\t", depth+1))
+			} else if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeTree(b, e, depth+1)
+		}
+		if nl {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("\t", depth))
+		}
+		b.WriteByte(')')
+	}
+}
@@ -0,0 +1,35 @@
+package layla
+
+import "testing"
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{`(text font:['bold' 10] x:4 'Hi')`, "(text x:4 font:{name:'bold' size:10} 'Hi')\n"},
+		{`(text font:['' 0 3], 'a')`, "(text font:{line:3} 'a')\n"},
+		{`(stage h:80 w:100 (text 'a')
+  (box y:2 (line x:1 border.w:2)))`,
+			"(stage w:100 h:80\n\t(text 'a')\n\t(box y:2\n\t\t(line x:1 border.w:2)\n\t)\n)\n"},
+		{`(vbox (if false (rect h:72)))`, "(vbox\n\t(if false\n\t\t(rect h:72)\n\t)\n)\n"},
+		{"(text (tr 'title') $n)\n\n\n(rect 'b' w:3)", "(text (tr 'title') $n)\n\n(rect w:3 'b')\n"},
+	}
+	for _, test := range tests {
+		got, err := FormatSource([]byte(test.raw), "t.layla")
+		if err != nil {
+			t.Errorf("format %s: %v", test.raw, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("format %s want:\n%s\ngot:\n%s", test.raw, test.want, got)
+		}
+		again, _ := FormatSource(got, "t.layla")
+		if string(again) != string(got) {
+			t.Errorf("format not idempotent for %s:\n%s", got, again)
+		}
+	}
+	_, err := FormatSource([]byte("(text 'a'\n(box)"), "t.layla")
+	if err == nil || err.Error() != "t.layla:1:1: unclosed (" {
+		t.Errorf("want unclosed error got %v", err)
+	}
+}
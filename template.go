@@ -0,0 +1,294 @@
+package layla
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/mb0/xelf/cor"
+	"github.com/mb0/xelf/exp"
+	"github.com/mb0/xelf/lit"
+	"github.com/mb0/xelf/typ"
+)
+
+// Loader loads templates from a file system and provides forms to compose them.
+//
+// The include form evaluates another template in place: (include 'footer.layla').
+// The import form evaluates a file of component definitions once: (import 'common.layla').
+// The component form defines a named component with parameters, that expands to the node
+// subtree of its body evaluated with the arguments as parameters:
+//
+//	(component address-block c (vbox (text $c.name) (text $c.street)))
+//	(address-block $customer)
+//
+// Components defined in imported files are kept by the loader, all other components are only
+// defined for the execution of one template.
+//
+// Paths are slash separated and relative to the including file, unless they start with a slash.
+type Loader struct {
+	FS fs.FS
+	// Env is the resolver environment used for all templates.
+	// It starts with the loader lookup followed by the default layla environment.
+	Env      exp.Builtin
+	forms    map[string]*exp.Spec
+	comps    map[string]*component
+	local    map[string]*component
+	stack    []string
+	calls    []string
+	imported map[string]bool
+	// importing is the number of imports on the include stack.
+	importing int
+}
+
+type component struct {
+	name   string
+	params []string
+	body   exp.El
+	env    exp.Env
+	spec   *exp.Spec
+}
+
+// NewLoader returns a new template loader for the file system fsys.
+func NewLoader(fsys fs.FS) *Loader {
+	l := &Loader{FS: fsys}
+	l.Env = append(exp.Builtin{l.Lookup}, Env...)
+	pathSig := []typ.Param{{Name: "path", Type: typ.Str}}
+	l.forms = map[string]*exp.Spec{
+		"include": {typ.Form("include", pathSig), exp.ResolverFunc(l.include)},
+		"import":  {typ.Form("import", pathSig), exp.ResolverFunc(l.importFile)},
+		"component": {typ.Form("component", []typ.Param{{Name: "name"}, {Name: "tail?"}}),
+			exp.ResolverFunc(l.define)},
+		"components": {typ.Form("components", []typ.Param{{Name: "tail?"}}),
+			exp.ResolverFunc(l.all)},
+	}
+	return l
+}
+
+// Lookup is the resolver lookup for the template forms and defined components.
+func (l *Loader) Lookup(sym string) *exp.Spec {
+	if f := l.forms[sym]; f != nil {
+		return f
+	}
+	if c := l.comps[sym]; c != nil {
+		return c.spec
+	}
+	if c := l.local[sym]; c != nil {
+		return c.spec
+	}
+	return nil
+}
+
+// Execute loads and executes the template with name using param as parameter, if not nil,
+// and returns a node or an error.
+func (l *Loader) Execute(name string, param lit.Lit) (*Node, error) {
+	name, err := l.path("/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { l.local = nil }()
+	var env exp.Env = l.Env
+	if param != nil {
+		env = &exp.ParamEnv{l.Env, param}
+	}
+	r, err := l.load(name, false, env, func(env exp.Env, x exp.El) (exp.El, error) {
+		return exp.Eval(env, x)
+	})
+	if err != nil {
+		return nil, err
+	}
+	a, _ := r.(*exp.Atom)
+	if a == nil || getNode(a.Lit) == nil {
+		return nil, cor.Errorf("template %s: expected *layla.Node got %s", name, r)
+	}
+	return rootNode(getNode(a.Lit)), nil
+}
+
+// load reads the file with name and calls eval with the expression and a file environment for env
+// while the name is on the include stack. Files of components are wrapped in a components form.
+// Nodes resolved in the file environment have the file name set to their source position.
+func (l *Loader) load(name string, comps bool, env exp.Env, eval evalFunc) (exp.El, error) {
+	for i, s := range l.stack {
+		if s == name {
+			cycle := append(l.stack[i:len(l.stack):len(l.stack)], name)
+			return nil, cor.Errorf("cyclic include %s", strings.Join(cycle, " -> "))
+		}
+	}
+	f, err := l.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if comps {
+		r = io.MultiReader(strings.NewReader("(components "), f, strings.NewReader(")"))
+	}
+	x, err := exp.Read(r)
+	if err != nil {
+		return nil, cor.Errorf("template %s: %v", name, err)
+	}
+	l.stack = append(l.stack, name)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	res, err := eval(&fileEnv{env, name}, x)
+	if err != nil {
+		var ne *NodeError
+		if errors.As(err, &ne) {
+			return nil, err
+		}
+		return nil, cor.Errorf("template %s: %v", name, err)
+	}
+	return res, nil
+}
+
+type evalFunc func(env exp.Env, x exp.El) (exp.El, error)
+
+// path returns the cleaned file system path for p relative to the current template.
+func (l *Loader) path(p string) (string, error) {
+	if strings.HasPrefix(p, "/") {
+		p = p[1:]
+	} else if len(l.stack) > 0 {
+		p = path.Join(path.Dir(l.stack[len(l.stack)-1]), p)
+	}
+	p = path.Clean(p)
+	if !fs.ValidPath(p) || p == "." {
+		return "", cor.Errorf("invalid template path %q", p)
+	}
+	return p, nil
+}
+
+func (l *Loader) pathArg(c *exp.Ctx, env exp.Env, x *exp.Call) (string, error) {
+	if len(x.Args) != 1 {
+		return "", cor.Errorf("%s expects one path argument", x)
+	}
+	el, err := c.Eval(env, x.Args[0], typ.Str)
+	if err != nil {
+		return "", err
+	}
+	if a, ok := el.(*exp.Atom); ok {
+		if s, ok := a.Lit.(lit.Str); ok {
+			return l.path(string(s))
+		}
+	}
+	return "", cor.Errorf("%s expects a path string got %s", x, el)
+}
+
+func (l *Loader) include(c *exp.Ctx, env exp.Env, x *exp.Call, hint typ.Type) (exp.El, error) {
+	name, err := l.pathArg(c, env, x)
+	if err != nil {
+		return nil, err
+	}
+	return l.load(name, false, env, func(env exp.Env, x exp.El) (exp.El, error) {
+		return c.Eval(env, x, hint)
+	})
+}
+
+func (l *Loader) importFile(c *exp.Ctx, env exp.Env, x *exp.Call, hint typ.Type) (exp.El, error) {
+	name, err := l.pathArg(c, env, x)
+	if err != nil {
+		return nil, err
+	}
+	if !l.imported[name] {
+		// components are defined in the loader environment without the caller parameters
+		l.importing++
+		_, err = l.load(name, true, l.Env, func(env exp.Env, x exp.El) (exp.El, error) {
+			return c.Eval(env, x, typ.Void)
+		})
+		l.importing--
+		if err != nil {
+			return nil, err
+		}
+		if l.imported == nil {
+			l.imported = make(map[string]bool)
+		}
+		l.imported[name] = true
+	}
+	return &exp.Atom{Lit: lit.Nil}, nil
+}
+
+func (l *Loader) all(c *exp.Ctx, env exp.Env, x *exp.Call, hint typ.Type) (exp.El, error) {
+	for _, arg := range x.Args {
+		_, err := c.Eval(env, arg, typ.Void)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &exp.Atom{Lit: lit.Nil}, nil
+}
+
+func (l *Loader) define(c *exp.Ctx, env exp.Env, x *exp.Call, hint typ.Type) (exp.El, error) {
+	if len(x.Args) < 2 {
+		return nil, cor.Errorf("component expects a name and body")
+	}
+	syms := make([]string, 0, len(x.Args)-1)
+	for _, arg := range x.Args[:len(x.Args)-1] {
+		s, ok := arg.(*exp.Sym)
+		if !ok {
+			return nil, cor.Errorf("component expects symbol names got %s", arg)
+		}
+		syms = append(syms, s.Name)
+	}
+	d := &component{name: syms[0], params: syms[1:], body: x.Args[len(x.Args)-1], env: env}
+	if l.forms[d.name] != nil || NodeLookup(d.name) != nil {
+		return nil, cor.Errorf("component %s would shadow a builtin form", d.name)
+	}
+	if l.comps[d.name] != nil || l.local[d.name] != nil {
+		return nil, cor.Errorf("component %s already defined", d.name)
+	}
+	ps := make([]typ.Param, 0, len(d.params))
+	for _, p := range d.params {
+		ps = append(ps, typ.Param{Name: p})
+	}
+	d.spec = &exp.Spec{typ.Form(d.name, ps), exp.ResolverFunc(l.expand(d))}
+	if l.importing == 0 {
+		if l.local == nil {
+			l.local = make(map[string]*component)
+		}
+		l.local[d.name] = d
+	} else {
+		if l.comps == nil {
+			l.comps = make(map[string]*component)
+		}
+		l.comps[d.name] = d
+	}
+	return &exp.Atom{Lit: lit.Nil}, nil
+}
+
+// expand returns a resolver function that evaluates the component body with the arguments.
+func (l *Loader) expand(d *component) exp.ResolverFunc {
+	return func(c *exp.Ctx, env exp.Env, x *exp.Call, hint typ.Type) (exp.El, error) {
+		for i, s := range l.calls {
+			if s == d.name {
+				cycle := append(l.calls[i:len(l.calls):len(l.calls)], d.name)
+				return nil, cor.Errorf("cyclic component %s", strings.Join(cycle, " -> "))
+			}
+		}
+		if len(x.Args) != len(d.params) {
+			return nil, cor.Errorf("component %s expects %d arguments got %d",
+				d.name, len(d.params), len(x.Args))
+		}
+		kv := make([]lit.Keyed, 0, len(d.params))
+		for i, arg := range x.Args {
+			el, err := c.Eval(env, arg, typ.Void)
+			if err != nil {
+				return nil, err
+			}
+			a, ok := el.(*exp.Atom)
+			if !ok {
+				return nil, cor.Errorf("component %s argument %s unresolved", d.name, d.params[i])
+			}
+			kv = append(kv, lit.Keyed{d.params[i], a.Lit})
+		}
+		l.calls = append(l.calls, d.name)
+		defer func() { l.calls = l.calls[:len(l.calls)-1] }()
+		res, err := c.Eval(&exp.ParamEnv{d.env, lit.RecFromKeyed(kv)}, d.body, hint)
+		if err != nil {
+			var ne *NodeError
+			if errors.As(err, &ne) {
+				return nil, err
+			}
+			return nil, cor.Errorf("component %s: %v", d.name, err)
+		}
+		return res, nil
+	}
+}
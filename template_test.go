@@ -0,0 +1,84 @@
+package layla
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mb0/xelf/lit"
+)
+
+func TestLoader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/common.layla": {Data: []byte(`
+			(component address-block c (vbox (text $c.name) (text $c.city)))
+			(component greeting name (text (cat 'Hello ' $name)))
+			(component frame c (box $c))
+		`)},
+		"lib/footer.layla": {Data: []byte(`(text $vendor)`)},
+		"label.layla": {Data: []byte(`(stage w:360 h:360
+			(import 'lib/common.layla')
+			(import 'lib/common.layla')
+			(greeting 'World')
+			(address-block {name:'Firma GmbH' city:'Berlin'})
+			(frame (text 'Framed'))
+			(include 'lib/footer.layla'))`)},
+		"a.layla":      {Data: []byte(`(box (include 'b.layla'))`)},
+		"b.layla":      {Data: []byte(`(box (include 'sub/../a.layla'))`)},
+		"rec.layla":    {Data: []byte(`(box (import 'rec.lib') (loop 1))`)},
+		"rec.lib":      {Data: []byte(`(component loop n (box (loop $n)))`)},
+		"bad.layla":    {Data: []byte(`(box (include '../x.layla'))`)},
+		"text.layla":   {Data: []byte(`(box (component text (box)))`)},
+		"inline.layla": {Data: []byte(`(box (component hello (text 'Hi')) (hello))`)},
+	}
+	l := NewLoader(fsys)
+	param := lit.RecFromKeyed([]lit.Keyed{{"vendor", lit.Str("Vendor")}})
+	n, err := l.Execute("label.layla", param)
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	var got, files []string
+	var walk func(*Node)
+	walk = func(n *Node) {
+		if n.Kind == "text" {
+			got = append(got, n.Data)
+		}
+		files = append(files, n.Kind+" "+n.Src.File)
+		for _, e := range n.List {
+			walk(e)
+		}
+	}
+	walk(n)
+	if want := "Hello World|Firma GmbH|Berlin|Framed|Vendor"; strings.Join(got, "|") != want {
+		t.Errorf("want texts %s got %s", want, strings.Join(got, "|"))
+	}
+	// component arguments keep the file of the caller
+	want := "stage label.layla|text lib/common.layla|vbox lib/common.layla|" +
+		"text lib/common.layla|text lib/common.layla|box lib/common.layla|" +
+		"text label.layla|text lib/footer.layla"
+	if got := strings.Join(files, "|"); got != want {
+		t.Errorf("want files %s got %s", want, got)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = l.Execute("inline.layla", nil)
+		if err != nil {
+			t.Errorf("execute inline component %d error: %v", i, err)
+		}
+	}
+	errs := []struct {
+		name string
+		want string
+	}{
+		{"a.layla", "cyclic include a.layla -> b.layla -> a.layla"},
+		{"rec.layla", "cyclic component loop -> loop"},
+		{"bad.layla", "invalid template path"},
+		{"text.layla", "component text would shadow a builtin form"},
+		{"missing.layla", "missing.layla"},
+	}
+	for _, e := range errs {
+		_, err := NewLoader(fsys).Execute(e.name, nil)
+		if err == nil || !strings.Contains(err.Error(), e.want) {
+			t.Errorf("%s want error %q got %v", e.name, e.want, err)
+		}
+	}
+}
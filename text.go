@@ -14,7 +14,7 @@ func (l *Layouter) lineLayout(n *Node, stack []*Node) (err error) {
 	markup := n.Kind == "markup"
 	var els []mark.El
 	if markup {
-		els, err = mark.Inline(n.Data)
+		els, err = markupEls(n.Data)
 		if err != nil {
 			return err
 		}
@@ -55,7 +55,10 @@ func (l *Layouter) lineLayout(n *Node, stack []*Node) (err error) {
 			if !markup {
 				buf.WriteString(sp.Text)
 			} else if sp.Text != " " {
-				of := of
+				of, link := of, sp.Link
+				if link == "" {
+					link = n.Link
+				}
 				if sp.Tag != 0 {
 					ofv := *of
 					ofv.Style = sp.Tag
@@ -69,6 +72,8 @@ func (l *Layouter) lineLayout(n *Node, stack []*Node) (err error) {
 						Dim: Dim{W: w, H: lh},
 					},
 					Font: of,
+					Link: link,
+					Src:  n.Src,
 				})
 			}
 			if x+w > mw {
@@ -125,7 +130,12 @@ func (s *splitter) lines(els []mark.El) (res []line, err error) {
 		if err != nil {
 			return res, err
 		}
-		res, cur = s.spans(f, el.Tag, el.Cont, res, cur)
+		cont, link := el.Cont, ""
+		if el.Tag&mark.A != 0 {
+			// link elements hold the url as content and the label as sub elements
+			cont, link = elText(el.Els), el.Cont
+		}
+		res, cur = s.spans(f, el.Tag, link, cont, res, cur)
 	}
 	if len(cur.Spans) > 0 {
 		res = append(res, cur)
@@ -142,6 +152,7 @@ type span struct {
 	Text string
 	W    float64
 	Tag  mark.Tag
+	Link string
 }
 
 func (s *splitter) splitSpan(f *font.Face, txt string, mw float64) (w float64, _, rest string) {
@@ -163,7 +174,7 @@ func (s *splitter) spanW(f *font.Face, txt string) float64 {
 	w += f.Extra()
 	return w
 }
-func (s *splitter) spans(f *font.Face, tag mark.Tag, cont string, res []line, cur line) ([]line, line) {
+func (s *splitter) spans(f *font.Face, tag mark.Tag, link, cont string, res []line, cur line) ([]line, line) {
 	var space bool
 	sdot := f.Rune(s.Spacer, -1)
 	for _, txt := range toks(cont) {
@@ -186,9 +197,9 @@ func (s *splitter) spans(f *font.Face, tag mark.Tag, cont string, res []line, cu
 		mw := s.Max - cur.W
 		if ww+ws < mw { // normal case: fits in cur line
 			if ws > 0 {
-				cur.Spans = append(cur.Spans, span{" ", ws, tag})
+				cur.Spans = append(cur.Spans, span{" ", ws, tag, link})
 			}
-			cur.Spans = append(cur.Spans, span{txt, ww, tag})
+			cur.Spans = append(cur.Spans, span{txt, ww, tag, link})
 			cur.W += math.Ceil(ws + ww)
 			continue
 		}
@@ -198,9 +209,9 @@ func (s *splitter) spans(f *font.Face, tag mark.Tag, cont string, res []line, cu
 			wf := s.spanW(f, fst)
 			if ws+wf < mw {
 				if ws > 0 {
-					cur.Spans = append(cur.Spans, span{" ", ws, tag})
+					cur.Spans = append(cur.Spans, span{" ", ws, tag, link})
 				}
-				cur.Spans = append(cur.Spans, span{fst, wf, tag})
+				cur.Spans = append(cur.Spans, span{fst, wf, tag, link})
 				cur.W += ws + wf
 				ww, ws = s.spanW(f, snd), 0
 				txt = snd
@@ -220,10 +231,10 @@ func (s *splitter) spans(f *font.Face, tag mark.Tag, cont string, res []line, cu
 				cw, ct, rest := s.splitSpan(f, txt, mw-ws)
 				cur.W += math.Ceil(ws + cw)
 				if ws > 0 {
-					cur.Spans = append(cur.Spans, span{" ", ws, tag})
+					cur.Spans = append(cur.Spans, span{" ", ws, tag, link})
 					ws = 0
 				}
-				cur.Spans = append(cur.Spans, span{ct, cw, tag})
+				cur.Spans = append(cur.Spans, span{ct, cw, tag, link})
 				ww = s.spanW(f, rest)
 				txt = rest
 				i++
@@ -232,10 +243,10 @@ func (s *splitter) spans(f *font.Face, tag mark.Tag, cont string, res []line, cu
 		if len(cur.Spans) > 0 {
 			res = append(res, cur)
 		}
-		cur = line{W: ww, Spans: []span{{txt, ww, tag}}}
+		cur = line{W: ww, Spans: []span{{txt, ww, tag, link}}}
 	}
 	if space {
-		cur.Spans = append(cur.Spans, span{" ", sdot, tag})
+		cur.Spans = append(cur.Spans, span{" ", sdot, tag, link})
 		cur.W += sdot
 	}
 	return res, cur
@@ -274,3 +285,49 @@ func toks(text string) (res []string) {
 	}
 	return res
 }
+
+// markupEls parses the markup text txt line by line and returns the inline elements separated by
+// line breaks. The elements of heading lines are tagged with the header tag.
+func markupEls(txt string) (res []mark.El, err error) {
+	for i, ln := range strings.Split(txt, "\n") {
+		if i > 0 {
+			res = append(res, mark.El{Cont: "\n"})
+		}
+		bls, err := (mark.Header | mark.Style).Parse(ln)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bls {
+			if b.Tag&mark.Header == 0 {
+				res = append(res, b.Els...)
+				continue
+			}
+			els, err := mark.Style.Inline(b.Cont)
+			if err != nil {
+				return nil, err
+			}
+			for _, el := range els {
+				el.Tag |= b.Tag
+				res = append(res, el)
+			}
+		}
+	}
+	return res, nil
+}
+
+// elShown returns the displayed text of the markup element el, that is the label of links.
+func elShown(el mark.El) string {
+	if el.Tag&mark.A != 0 {
+		return elText(el.Els)
+	}
+	return el.Cont
+}
+
+func elText(els []mark.El) string {
+	var b strings.Builder
+	for _, el := range els {
+		b.WriteString(el.Cont)
+		b.WriteString(elText(el.Els))
+	}
+	return b.String()
+}
@@ -1,7 +1,9 @@
 package layla
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/mb0/layla/font"
@@ -23,7 +25,7 @@ func TestLayout(t *testing.T) {
 		{"To be or-not to be", 54, "To be or-\nnot to be"},
 		{"To be\nor not\nto be", 50, "To be\nor not\nto be"},
 	}
-	lay := &Layouter{m, ' ', ZeroStyler}
+	lay := &Layouter{Manager: m, Spacer: ' ', Styler: ZeroStyler}
 	for i, test := range tests {
 		n := &Node{
 			Kind: "text",
@@ -62,3 +64,33 @@ func TestTokens(t *testing.T) {
 		}
 	}
 }
+
+func TestMarkupSpans(t *testing.T) {
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"## Some *Title*", "Some 32 \nTitle 33 \n"},
+		{"See [the docs](http://x.org) now", "" +
+			"See 0 \nthe 8 http://x.org\ndocs 8 http://x.org\nnow 0 \n"},
+		{"#Tag", "Tag 16 \n"},
+		{"# Title\nBody *text*", "Title 16 \nBody 0 \ntext 1 \n"},
+	}
+	lay := &Layouter{Manager: m, Spacer: ' ', Styler: ZeroStyler}
+	for _, test := range tests {
+		n := &Node{Kind: "markup", Data: test.text, Calc: Box{Dim: Dim{W: 400}}}
+		err := lay.lineLayout(n, nil)
+		if err != nil {
+			t.Errorf("layout error: %v", err)
+			continue
+		}
+		var b strings.Builder
+		for _, c := range n.List {
+			fmt.Fprintf(&b, "%s %d %s\n", c.Data, c.Font.Style, c.Link)
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("test %q want spans:\n%sgot:\n%s", test.text, test.want, got)
+		}
+	}
+}
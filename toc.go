@@ -0,0 +1,230 @@
+package layla
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mb0/layla/mark"
+)
+
+// tocEntry is a table of contents entry for a heading node. Headings in markup nodes start at
+// the offset off in the displayed text, counted like footnote references, otherwise off is -1.
+type tocEntry struct {
+	Node  *Node
+	Level int
+	Title string
+	Page  string
+	off   int
+}
+
+// prepToc collects the entries of all toc nodes in the tree n, unless already collected.
+//
+// Entries are the headings of level one to four in markup nodes and nodes with the toc attribute
+// set to the entry level. The title of headings is the heading text, that of text and markup
+// nodes with the toc attribute their text and that of other nodes the title of their first text
+// or markup descendant. Extra, cover, header, footer and footnote nodes are ignored.
+func prepToc(n *Node) {
+	var tocs []*Node
+	var entries []*tocEntry
+	var walk func(*Node)
+	walk = func(n *Node) {
+		switch n.Kind {
+		case "extra", "cover", "header", "footer", "footnote":
+			return
+		case "toc":
+			if n.toc == nil {
+				tocs = append(tocs, n)
+			}
+			return
+		}
+		if n.Toc > 0 {
+			entries = append(entries, &tocEntry{n, n.Toc, tocTitle(n), "1", -1})
+		} else if n.Kind == "markup" {
+			entries = append(entries, headEntries(n)...)
+		}
+		for _, e := range n.List {
+			walk(e)
+		}
+	}
+	walk(n)
+	for _, t := range tocs {
+		t.toc = entries
+		if t.toc == nil {
+			t.toc = []*tocEntry{}
+		}
+	}
+}
+
+// headLevel returns the heading level one to four for the header tag or zero.
+func headLevel(tag mark.Tag) (level int) {
+	for h := mark.H1; h <= mark.H4; h <<= 1 {
+		level++
+		if tag&h != 0 {
+			return level
+		}
+	}
+	return 0
+}
+
+// headEntries returns a toc entry for each heading line of level one to four in markup node n.
+func headEntries(n *Node) (res []*tocEntry) {
+	data := n.Data
+	if n.fld != nil {
+		data = n.fld.cur
+	}
+	els, err := markupEls(data)
+	if err != nil {
+		return nil
+	}
+	var cur *tocEntry
+	var title strings.Builder
+	end := func() {
+		if cur != nil {
+			cur.Title = strings.Join(strings.Fields(title.String()), " ")
+			res = append(res, cur)
+		}
+		cur = nil
+		title.Reset()
+	}
+	var off int
+	for _, el := range els {
+		if el.Cont == "\n" && el.Tag == 0 {
+			end()
+			continue
+		}
+		txt := elShown(el)
+		if level := headLevel(el.Tag); level > 0 {
+			if cur == nil {
+				cur = &tocEntry{Node: n, Level: level, Page: "1", off: off}
+			}
+			title.WriteString(txt)
+		}
+		off += nonSpace(txt)
+	}
+	end()
+	return res
+}
+
+func tocTitle(n *Node) string {
+	switch n.Kind {
+	case "text", "markup":
+		data := n.Data
+		if n.fld != nil {
+			data = n.fld.cur
+		}
+		if n.Kind == "markup" {
+			if els, err := markupEls(data); err == nil {
+				data = elText(els)
+			}
+		}
+		return strings.Join(strings.Fields(data), " ")
+	}
+	for _, e := range n.List {
+		if t := tocTitle(e); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// tocLayout generates and lays out the entries of the toc node n. Each entry consists of the
+// title indented by one line height per level, a leader of dots and the right aligned page
+// number on the last line of the title. Entries are separated by the gap.
+func (l *Layouter) tocLayout(n *Node, stack []*Node) error {
+	stack = append(stack, n)
+	a := n.Pad.Inset(n.Calc)
+	of := getFont(stack)
+	lh, err := l.lineHeight(of)
+	if err != nil {
+		return err
+	}
+	face, err := l.Styler(l.Manager, *of, mark.Text)
+	if err != nil {
+		return err
+	}
+	width := func(txt string) float64 {
+		w, _ := face.Text(txt, -1)
+		return math.Ceil(w + face.Extra())
+	}
+	var numW float64
+	for _, e := range n.toc {
+		numW = math.Max(numW, width(e.Page))
+	}
+	dotW := width(".")
+	n.List = make([]*Node, 0, len(n.toc)*3)
+	var h float64
+	for i, e := range n.toc {
+		indent := lh * float64(e.Level-1)
+		title := &Node{Kind: "text", Data: e.Title, Src: e.Node.Src}
+		tb := Box{Pos: Pos{X: a.X + indent, Y: a.Y + h}}
+		tb.W = a.W - indent - numW - 2*dotW
+		if tb.W <= 0 {
+			tb.W = a.W - indent
+		}
+		eb, err := l.layout(title, tb, stack)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(title.Data, "\n")
+		ly := title.Calc.Y + title.Calc.H - lh
+		pw := width(e.Page)
+		num := &Node{Kind: "text", Data: e.Page, Src: e.Node.Src}
+		_, err = l.layout(num, Box{Pos: Pos{X: a.X + a.W - pw, Y: ly}, Dim: Dim{W: pw + 1}}, stack)
+		if err != nil {
+			return err
+		}
+		n.List = append(n.List, title)
+		x0 := title.Calc.X + width(lines[len(lines)-1]) + dotW
+		if c := int((num.Calc.X - dotW - x0) / dotW); c > 0 {
+			dots := &Node{Kind: "text", Data: strings.Repeat(".", c), Src: e.Node.Src}
+			lb := Box{Pos: Pos{X: x0, Y: ly}, Dim: Dim{W: float64(c+1) * dotW}}
+			_, err = l.layout(dots, lb, stack)
+			if err != nil {
+				return err
+			}
+			n.List = append(n.List, dots)
+		}
+		n.List = append(n.List, num)
+		h += eb.H
+		if i < len(n.toc)-1 {
+			h += n.Gap
+		}
+	}
+	n.Calc.H = clamp(n.Calc.H, h)
+	return nil
+}
+
+// resolveToc sets the page numbers of toc entries in the tree n from the paging result p and
+// returns whether any page number changed.
+func resolveToc(n *Node, p *pager) (changed bool) {
+	if n.toc != nil {
+		for _, e := range n.toc {
+			page := strconv.Itoa(e.at(p) + 1)
+			if page != e.Page {
+				e.Page = page
+				changed = true
+			}
+		}
+	}
+	for _, e := range n.List {
+		if resolveToc(e, p) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// at returns the index of the page the entry e starts on in the paging result p.
+func (e *tocEntry) at(p *pager) int {
+	if e.off >= 0 {
+		// the heading starts in the markup span containing its first displayed rune
+		var c int
+		for _, s := range e.Node.List {
+			if c += nonSpace(s.Data); c > e.off {
+				return p.at[s]
+			}
+		}
+	}
+	return p.at[e.Node]
+}
@@ -0,0 +1,52 @@
+package layla
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mb0/layla/font"
+)
+
+func TestToc(t *testing.T) {
+	m := font.NewManager(72, 2, 4).RegisterTTF("", "testdata/font/Go-Regular.ttf")
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`(page w:200 h:100 font.line:10 (vbox
+			(toc)
+			(markup '# Intro *one*')
+			(rect h:80)
+			(markup '## Details\nBody *text*\n### More')
+			(rect h:80)
+			(box toc:1 (text 'Appendix'))))`, []string{
+			"0 0 Intro one", "146 0 ...", "181 0 1",
+			"10 10 Details", "125 10 ...", "181 10 2",
+			// each heading is an entry on the page of its line
+			"20 20 More", "107 20 ...", "181 20 3",
+			"0 30 Appendix", "151 30 ...", "181 30 3",
+			"height 40",
+		}},
+	}
+	for _, test := range tests {
+		n, _, err := pageSource(m, test.raw, nil)
+		if err != nil {
+			t.Errorf("for %s error: %v", test.raw, err)
+			continue
+		}
+		toc := n.List[0].List[0]
+		var got []string
+		for _, e := range toc.List {
+			if e.Data != "" && strings.Trim(e.Data, ".") == "" {
+				e.Data = "..."
+			}
+			got = append(got, fmt.Sprintf("%g %g %s", e.Calc.X, e.Calc.Y, e.Data))
+		}
+		got = append(got, fmt.Sprintf("height %g", toc.Calc.H))
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("for %s\nwant toc:\n%s\ngot:\n%s", test.raw,
+				strings.Join(test.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
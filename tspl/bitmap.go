@@ -0,0 +1,158 @@
+package tspl
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/mark"
+	"github.com/mb0/xelf/bfr"
+	"github.com/mb0/xelf/cor"
+	xfont "golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// TextMode selects how text nodes are sent to the printer.
+type TextMode int
+
+const (
+	// TextPrinter uses the printer's built-in font "0".
+	TextPrinter TextMode = iota
+	// TextDownload downloads the registered TTF files to the printer and references them by name.
+	TextDownload
+	// TextBitmap rasterises text with the registered TTF and sends it as bitmap.
+	// The font manager should use the printer resolution for the bitmap to match the layout.
+	TextBitmap
+)
+
+// fontName returns the printer font name used for text node d.
+func (j *Job) fontName(d *layla.Node) string {
+	if j.Text != TextDownload || d.Font == nil {
+		return "0"
+	}
+	return fontFile(d.Font.Name)
+}
+
+// fontFile returns the printer file name for the font with name.
+func fontFile(name string) string {
+	if name == "" {
+		name = "layla"
+	}
+	return strings.ToUpper(name) + ".TTF"
+}
+
+// download writes a DOWNLOAD command for each font in draw not yet sent to the printer.
+func (j *Job) download(b bfr.B, draw []*layla.Node) error {
+	for _, d := range draw {
+		if d.Kind != "text" || d.Font == nil || j.loaded[d.Font.Name] {
+			continue
+		}
+		src, err := j.Source(d.Font.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "DOWNLOAD %q,%d,", fontFile(d.Font.Name), len(src.Data))
+		b.Write(src.Data)
+		b.WriteByte('\n')
+		if j.loaded == nil {
+			j.loaded = make(map[string]bool)
+		}
+		j.loaded[d.Font.Name] = true
+	}
+	return nil
+}
+
+// renderBitmap rasterises the text node d and writes it as BITMAP command to b.
+func (j *Job) renderBitmap(b bfr.B, d *layla.Node) error {
+	img, err := j.rasterText(d, layla.RotDim(d.Dim, d.Rot))
+	if err != nil {
+		return err
+	}
+	img = rotate(img, d.Rot)
+	r := img.Bounds()
+	bw := (r.Dx() + 7) / 8
+	fmt.Fprintf(b, "BITMAP %d,%d,%d,%d,0,", dot(d.X), dot(d.Y), bw, r.Dy())
+	row := make([]byte, bw)
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		// the printer prints a dot for each zero bit
+		for i := range row {
+			row[i] = 0xff
+		}
+		for px := r.Min.X; px < r.Max.X; px++ {
+			if img.GrayAt(px, py).Y < 0x80 {
+				i := px - r.Min.X
+				row[i/8] &^= 0x80 >> uint(i%8)
+			}
+		}
+		b.Write(row)
+	}
+	b.WriteByte('\n')
+	return nil
+}
+
+// rasterText draws the lines of text node d with the registered font into a new image
+// of the unrotated content dimension c.
+func (j *Job) rasterText(d *layla.Node, c layla.Dim) (*image.Gray, error) {
+	if d.Font == nil {
+		return nil, cor.Errorf("text without font %q", d.Data)
+	}
+	f, err := j.Styler(j.Manager, *d.Font, d.Font.Style)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewGray(image.Rect(0, 0, dot(c.W), dot(c.H)))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	dr := &xfont.Drawer{Dst: img, Src: image.NewUniform(color.Black), Face: f.Face}
+	b := d.Pad.Inset(layla.Box{Dim: c})
+	asc := f.Metrics().Ascent
+	for i, txt := range strings.Split(d.Data, "\n") {
+		x := b.X
+		if w := j.PtToDot(dr.MeasureString(txt)); w < b.W {
+			switch d.Align {
+			case layla.AlignRight:
+				x += b.W - w
+			case layla.AlignCenter:
+				x += (b.W - w) / 2
+			}
+		}
+		y := b.Y + float64(i)*d.Font.Line
+		dr.Dot = fixed.Point26_6{X: j.DotToPt(x), Y: j.DotToPt(y) + asc}
+		dr.DrawString(txt)
+		if d.Font.Style&mark.B != 0 {
+			dr.Dot = fixed.Point26_6{X: j.DotToPt(x + 1), Y: j.DotToPt(y) + asc}
+			dr.DrawString(txt)
+		}
+	}
+	return img, nil
+}
+
+// rotate returns img rotated clockwise by rot degrees.
+func rotate(img *image.Gray, rot int) *image.Gray {
+	if rot == 0 {
+		return img
+	}
+	r := img.Bounds()
+	w, h := r.Dx(), r.Dy()
+	res := image.NewGray(image.Rect(0, 0, w, h))
+	if rot != 180 {
+		res = image.NewGray(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.GrayAt(r.Min.X+x, r.Min.Y+y)
+			switch rot {
+			case 90:
+				res.SetGray(h-1-y, x, c)
+			case 180:
+				res.SetGray(w-1-x, h-1-y, c)
+			case 270:
+				res.SetGray(y, w-1-x, c)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,286 @@
+package tspl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+	"github.com/mb0/xelf/bfr"
+	"github.com/mb0/xelf/cor"
+	"github.com/mb0/xelf/exp"
+	"github.com/mb0/xelf/lit"
+)
+
+// Counter is a printer side serial number counter.
+// Counters are referenced from text and barcode data as @0 to @9, where the digit is the counter
+// index in the job. The printer increments the counter by step after each printed label set.
+// Counter references cannot be used with numeric barcodes, because they fail data validation.
+type Counter struct {
+	Start string
+	Step  int
+}
+
+// Job renders multiple labels to a single TSPL stream.
+// The printer setup is only written once before the first label, each label is followed by
+// a PRINT command.
+type Job struct {
+	*layla.Layouter
+	Extra    []string
+	Counters []Counter
+	// Text selects how text nodes are sent to the printer.
+	Text    TextMode
+	started bool
+	loaded  map[string]bool
+}
+
+// NewJob returns a new job using the font manager and extra setup commands.
+func NewJob(man *font.Manager, extra ...string) *Job {
+	lay := &layla.Layouter{Manager: man, Spacer: 'i', Styler: layla.FakeBoldStyler}
+	return &Job{Layouter: lay, Extra: extra}
+}
+
+// Label renders the node n followed by a PRINT command for the number of label sets and copies
+// of each set. Counters are incremented for each set but not for copies.
+func (j *Job) Label(b bfr.B, n *layla.Node, sets, copies int) error {
+	l, err := j.prepare(n)
+	if err != nil {
+		return err
+	}
+	err = j.write(b, l)
+	if err != nil {
+		return err
+	}
+	printCmd(b, sets, copies)
+	return nil
+}
+
+// Records executes the template tmpl for each record and renders the resulting labels with
+// the given number of copies like Batch. Each record is used as parameter environment with env
+// as parent.
+func (j *Job) Records(b bfr.B, env exp.Env, tmpl string, recs []lit.Lit, copies int) error {
+	ns := make([]*layla.Node, 0, len(recs))
+	for i, rec := range recs {
+		n, err := layla.ExecuteString(&exp.ParamEnv{env, rec}, tmpl)
+		if err != nil {
+			return cor.Errorf("record %d: %v", i, err)
+		}
+		ns = append(ns, n)
+	}
+	return j.Batch(b, ns, copies)
+}
+
+// formFile is the printer file name of the label program used by Batch.
+const formFile = "LAYLA.BAS"
+
+// Batch renders the labels ns with the given number of copies of each label.
+//
+// If the laid out labels only differ in the data of text and code nodes, the first label is sent
+// once as printer program with the data of those nodes in string variables V0$, V1$ and so on.
+// Each label then only sends the variable values and runs the program. Otherwise each label is
+// sent in full.
+func (j *Job) Batch(b bfr.B, ns []*layla.Node, copies int) error {
+	ls := make([]*label, 0, len(ns))
+	for i, n := range ns {
+		l, err := j.prepare(n)
+		if err != nil {
+			return cor.Errorf("record %d: %v", i, err)
+		}
+		ls = append(ls, l)
+	}
+	vars, ok := j.formVars(ls)
+	if !ok || len(ls) < 2 {
+		for i, l := range ls {
+			err := j.write(b, l)
+			if err != nil {
+				return cor.Errorf("record %d: %v", i, err)
+			}
+			printCmd(b, 1, copies)
+		}
+		return nil
+	}
+	err := j.start(b, ls...)
+	if err != nil {
+		return err
+	}
+	form := *ls[0]
+	form.draw = make([]*layla.Node, len(form.draw))
+	copy(form.draw, ls[0].draw)
+	for k, i := range vars {
+		d := *form.draw[i]
+		d.Data = fmt.Sprintf("V%d$", k)
+		form.draw[i] = &d
+	}
+	fmt.Fprintf(b, "DOWNLOAD %q\n", formFile)
+	err = j.drawAll(b, &form)
+	if err != nil {
+		return err
+	}
+	printCmd(b, 1, copies)
+	b.WriteString("EOP\n")
+	for _, l := range ls {
+		for k, i := range vars {
+			fmt.Fprintf(b, "V%d$ = %s\n", k, quote(l.draw[i].Data))
+		}
+		fmt.Fprintf(b, "RUN %q\n", formFile)
+	}
+	return nil
+}
+
+// label is a laid out and rotated label.
+type label struct {
+	draw []*layla.Node
+	dim  layla.Dim
+	gap  float64
+}
+
+// prepare lays out, pages and rotates the node n.
+func (j *Job) prepare(n *layla.Node) (*label, error) {
+	draw, err := j.LayoutAndPage(n)
+	if err != nil {
+		return nil, err
+	}
+	dim, err := layla.Rotate(draw, n.Dim, n.Rot)
+	if err != nil {
+		return nil, err
+	}
+	return &label{draw, dim, n.Gap}, nil
+}
+
+// formVars returns the display node indices of the labels ls that only differ in data and
+// whether the labels do not differ otherwise.
+func (j *Job) formVars(ls []*label) (vars []int, ok bool) {
+	if len(ls) == 0 {
+		return nil, false
+	}
+	first := ls[0]
+	for _, l := range ls[1:] {
+		if len(l.draw) != len(first.draw) || l.dim != first.dim || l.gap != first.gap {
+			return nil, false
+		}
+	}
+	for i, d := range first.draw {
+		var vary bool
+		for _, l := range ls[1:] {
+			e := l.draw[i]
+			if e.Data != d.Data {
+				vary = true
+			}
+			dc, ec := *d, *e
+			dc.Data, ec.Data = "", ""
+			if !reflect.DeepEqual(dc, ec) {
+				return nil, false
+			}
+		}
+		if !vary {
+			continue
+		}
+		switch d.Kind {
+		case "text":
+			if j.Text == TextBitmap {
+				return nil, false
+			}
+		case "barcode", "qrcode":
+		default:
+			return nil, false
+		}
+		vars = append(vars, i)
+	}
+	return vars, true
+}
+
+// start writes the printer setup once and downloads the fonts used by the labels ls.
+func (j *Job) start(b bfr.B, ls ...*label) error {
+	if !j.started {
+		err := j.setup(b, ls[0].dim, ls[0].gap)
+		if err != nil {
+			return err
+		}
+		j.started = true
+	}
+	if j.Text == TextDownload {
+		for _, l := range ls {
+			err := j.download(b, l.draw)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write writes the label l without PRINT command.
+func (j *Job) write(b bfr.B, l *label) error {
+	err := j.start(b, l)
+	if err != nil {
+		return err
+	}
+	return j.drawAll(b, l)
+}
+
+// drawAll writes a CLS command and the draw commands of label l.
+func (j *Job) drawAll(b bfr.B, l *label) error {
+	b.WriteString("CLS\n")
+	for _, d := range l.draw {
+		err := j.renderNode(b, d)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printCmd(b bfr.B, sets, copies int) {
+	if sets < 1 {
+		sets = 1
+	}
+	if copies < 1 {
+		copies = 1
+	}
+	fmt.Fprintf(b, "PRINT %d,%d\n", sets, copies)
+}
+
+func (j *Job) setup(b bfr.B, dim layla.Dim, gap float64) error {
+	if len(j.Counters) > 10 {
+		return cor.Errorf("at most 10 counters supported got %d", len(j.Counters))
+	}
+	fmt.Fprintf(b, "SIZE %g mm, %g mm\n", dim.W/8, dim.H/8)
+	fmt.Fprintf(b, "GAP %g mm, 0 mm\n", gap/8)
+	b.WriteString("DIRECTION 1,0\nCODEPAGE UTF-8\n")
+	for _, line := range j.Extra {
+		b.WriteString(line)
+		if len(line) > 0 && line[len(line)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+	for i, c := range j.Counters {
+		fmt.Fprintf(b, "SET COUNTER @%d %d\n", i, c.Step)
+		fmt.Fprintf(b, "@%d = %q\n", i, c.Start)
+	}
+	return nil
+}
+
+// counterRef returns whether data is a counter reference of the form @0 to @9.
+func counterRef(data string) bool {
+	if len(data) != 2 || data[0] != '@' {
+		return false
+	}
+	_, err := strconv.Atoi(data[1:])
+	return err == nil
+}
+
+// varRef returns whether data is a string variable reference of the form V0$ used by Batch.
+func varRef(data string) bool {
+	if len(data) < 3 || data[0] != 'V' || data[len(data)-1] != '$' {
+		return false
+	}
+	_, err := strconv.Atoi(data[1 : len(data)-1])
+	return err == nil
+}
+
+// quote returns data as quoted TSPL string with line breaks as used by the BLOCK command.
+func quote(data string) string {
+	return strings.Replace(fmt.Sprintf("%q", data), "\\n", "\\[L]", -1)
+}
@@ -0,0 +1,260 @@
+package tspl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mb0/layla"
+	"github.com/mb0/layla/font"
+	"github.com/mb0/xelf/cor"
+)
+
+// Port is the default raw printing port of TSC network printers.
+const Port = "9100"
+
+// Printer errors as reported by the status command.
+var (
+	ErrHeadOpen  = errors.New("printer head open")
+	ErrCoverOpen = errors.New("printer cover open")
+	ErrPaperJam  = errors.New("printer paper jam")
+	ErrPaperOut  = errors.New("printer out of paper")
+	ErrRibbonOut = errors.New("printer out of ribbon")
+	ErrOther     = errors.New("printer error")
+	ErrTimeout   = errors.New("printer timeout")
+)
+
+// JobError is returned by Send if the connection failed after the job was partly or fully
+// written. The job may have been printed and is therefore never retried.
+type JobError struct {
+	Err error
+}
+
+func (e *JobError) Error() string { return "printer job: " + e.Err.Error() }
+func (e *JobError) Unwrap() error { return e.Err }
+
+// Status is the status byte returned by the printer for the <ESC>!? command.
+type Status byte
+
+const (
+	StatusHeadOpen Status = 1 << iota
+	StatusPaperJam
+	StatusPaperOut
+	StatusRibbonOut
+	StatusPause
+	StatusPrinting
+	StatusCoverOpen
+	StatusOther
+)
+
+// Err returns the most relevant printer error for the status or nil.
+func (s Status) Err() error {
+	switch {
+	case s&StatusHeadOpen != 0:
+		return ErrHeadOpen
+	case s&StatusCoverOpen != 0:
+		return ErrCoverOpen
+	case s&StatusPaperJam != 0:
+		return ErrPaperJam
+	case s&StatusPaperOut != 0:
+		return ErrPaperOut
+	case s&StatusRibbonOut != 0:
+		return ErrRibbonOut
+	case s&StatusOther != 0:
+		return ErrOther
+	}
+	return nil
+}
+
+// String returns a list of all set status flags or "ready".
+func (s Status) String() string {
+	if s == 0 {
+		return "ready"
+	}
+	names := []string{"head open", "paper jam", "paper out", "ribbon out",
+		"pause", "printing", "cover open", "other error"}
+	var res []string
+	for i, name := range names {
+		if s&(1<<uint(i)) != 0 {
+			res = append(res, name)
+		}
+	}
+	return strings.Join(res, ", ")
+}
+
+// Printer sends TSPL jobs to a printer connection.
+// Each operation opens a new connection and closes it afterwards.
+type Printer struct {
+	// Dial opens a new connection to the printer.
+	Dial func() (io.ReadWriteCloser, error)
+	// Timeout limits the duration of each attempt. Zero means no timeout.
+	// Connections with deadline support, like network connections and usb devices opened by USB,
+	// use a deadline. Other connections are closed after the timeout to unblock pending calls.
+	Timeout time.Duration
+	// Retries is the number of times a failed attempt is repeated. Only failed dials and status
+	// queries are retried. Printer status errors like paper out and job errors are never retried.
+	Retries int
+	// Wait is the delay between attempts.
+	Wait time.Duration
+}
+
+// TCP returns a printer for the network address addr. The port defaults to 9100.
+func TCP(addr string) *Printer {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, Port)
+	}
+	p := &Printer{Timeout: 10 * time.Second, Retries: 2, Wait: time.Second}
+	p.Dial = func() (io.ReadWriteCloser, error) {
+		return net.DialTimeout("tcp", addr, p.Timeout)
+	}
+	return p
+}
+
+// USB returns a printer for the linux usb line printer device at path, usually /dev/usb/lp0.
+func USB(path string) *Printer {
+	p := &Printer{Timeout: 10 * time.Second, Retries: 2, Wait: time.Second}
+	p.Dial = func() (io.ReadWriteCloser, error) {
+		return os.OpenFile(path, os.O_RDWR, 0)
+	}
+	return p
+}
+
+// Print renders the node n as TSPL and sends it to the printer or returns an error.
+func (p *Printer) Print(man *font.Manager, n *layla.Node, extra ...string) error {
+	var b bytes.Buffer
+	err := RenderBfr(&b, man, n, extra...)
+	if err != nil {
+		return err
+	}
+	return p.Send(b.Bytes())
+}
+
+// Send checks the printer status and writes the job to the printer or returns an error.
+// Errors after the job write started are returned as job errors.
+func (p *Printer) Send(job []byte) error {
+	return p.retry(func(c *conn) error {
+		s, err := readStatus(c)
+		if err != nil {
+			return err
+		}
+		if err = s.Err(); err != nil {
+			return err
+		}
+		atomic.StoreInt32(&c.sent, 1)
+		_, err = c.Write(job)
+		return err
+	})
+}
+
+// Status queries and returns the printer status or an error.
+func (p *Printer) Status() (s Status, err error) {
+	err = p.retry(func(c *conn) (err error) {
+		s, err = readStatus(c)
+		return err
+	})
+	return s, err
+}
+
+func readStatus(c io.ReadWriter) (Status, error) {
+	_, err := io.WriteString(c, "\x1b!?")
+	if err != nil {
+		return 0, err
+	}
+	var b [1]byte
+	_, err = io.ReadFull(c, b[:])
+	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return 0, ErrTimeout
+		}
+		return 0, cor.Errorf("read printer status: %v", err)
+	}
+	return Status(b[0]), nil
+}
+
+// conn is a printer connection that records whether the job write was started.
+type conn struct {
+	io.ReadWriteCloser
+	sent int32
+}
+
+func (p *Printer) retry(f func(*conn) error) (err error) {
+	for i := 0; i <= p.Retries; i++ {
+		if i > 0 && p.Wait > 0 {
+			time.Sleep(p.Wait)
+		}
+		err = p.attempt(f)
+		var je *JobError
+		if err == nil || isStatusErr(err) || errors.As(err, &je) {
+			return err
+		}
+	}
+	return err
+}
+
+// attempt dials the printer and calls f with the connection. Errors after f started to write the
+// job are returned as job errors.
+func (p *Printer) attempt(f func(*conn) error) error {
+	if p.Dial == nil {
+		return cor.Errorf("printer without dial function")
+	}
+	rwc, err := p.Dial()
+	if err != nil {
+		return err
+	}
+	c := &conn{ReadWriteCloser: rwc}
+	err = p.call(f, c)
+	if err != nil && atomic.LoadInt32(&c.sent) != 0 {
+		return &JobError{err}
+	}
+	return err
+}
+
+type deadliner interface {
+	SetDeadline(time.Time) error
+}
+
+// call calls f with the connection c and closes it afterwards. If c supports deadlines the
+// timeout is used as deadline, otherwise c is closed after the timeout to unblock f.
+func (p *Printer) call(f func(*conn) error, c *conn) error {
+	if p.Timeout <= 0 {
+		defer c.Close()
+		return f(c)
+	}
+	if d, ok := c.ReadWriteCloser.(deadliner); ok {
+		if d.SetDeadline(time.Now().Add(p.Timeout)) == nil {
+			defer c.Close()
+			err := f(c)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return ErrTimeout
+			}
+			return err
+		}
+	}
+	res := make(chan error, 1)
+	go func() { res <- f(c) }()
+	t := time.NewTimer(p.Timeout)
+	defer t.Stop()
+	select {
+	case err := <-res:
+		c.Close()
+		return err
+	case <-t.C:
+		// closing the connection unblocks the pending read or write of most connections,
+		// the goroutine ends with the pending call
+		c.Close()
+		return ErrTimeout
+	}
+}
+
+func isStatusErr(err error) bool {
+	switch err {
+	case ErrHeadOpen, ErrCoverOpen, ErrPaperJam, ErrPaperOut, ErrRibbonOut, ErrOther:
+		return true
+	}
+	return false
+}
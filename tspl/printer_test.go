@@ -0,0 +1,169 @@
+package tspl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakePrinter accepts connections, answers status requests with status and records all jobs.
+type fakePrinter struct {
+	net.Listener
+	status Status
+	silent bool
+	jobs   chan []byte
+}
+
+func newFakePrinter(t *testing.T, status Status, silent bool) *fakePrinter {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakePrinter{Listener: l, status: status, silent: silent, jobs: make(chan []byte, 8)}
+	go f.serve()
+	return f
+}
+
+func (f *fakePrinter) serve() {
+	for {
+		c, err := f.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(c)
+	}
+}
+
+func (f *fakePrinter) handle(c net.Conn) {
+	defer c.Close()
+	var job bytes.Buffer
+	buf := make([]byte, 512)
+	for {
+		n, err := c.Read(buf)
+		job.Write(buf[:n])
+		if bytes.HasSuffix(job.Bytes(), []byte("\x1b!?")) {
+			job.Truncate(job.Len() - 3)
+			if !f.silent {
+				c.Write([]byte{byte(f.status)})
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+	}
+	if job.Len() > 0 {
+		f.jobs <- job.Bytes()
+	}
+}
+
+func TestPrinterSend(t *testing.T) {
+	f := newFakePrinter(t, 0, false)
+	defer f.Close()
+	p := TCP(f.Addr().String())
+	job := []byte("SIZE 50 mm, 30 mm\nCLS\nPRINT 1,1\n")
+	err := p.Send(job)
+	if err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case got := <-f.jobs:
+		if !bytes.Equal(got, job) {
+			t.Errorf("want job %q got %q", job, got)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("printer received no job")
+	}
+}
+
+func TestPrinterStatus(t *testing.T) {
+	tests := []struct {
+		status Status
+		err    error
+	}{
+		{0, nil},
+		{StatusPrinting, nil},
+		{StatusPaperOut, ErrPaperOut},
+		{StatusHeadOpen | StatusPaperOut, ErrHeadOpen},
+		{StatusRibbonOut, ErrRibbonOut},
+	}
+	for _, test := range tests {
+		f := newFakePrinter(t, test.status, false)
+		p := TCP(f.Addr().String())
+		s, err := p.Status()
+		if err != nil {
+			t.Errorf("status error: %v", err)
+		} else if s != test.status {
+			t.Errorf("want status %s got %s", test.status, s)
+		}
+		err = p.Send([]byte("CLS\n"))
+		if err != test.err {
+			t.Errorf("status %s want error %v got %v", test.status, test.err, err)
+		}
+		f.Close()
+	}
+}
+
+func TestPrinterTimeout(t *testing.T) {
+	f := newFakePrinter(t, 0, true)
+	defer f.Close()
+	p := TCP(f.Addr().String())
+	p.Timeout = 50 * time.Millisecond
+	p.Retries = 1
+	p.Wait = 0
+	_, err := p.Status()
+	if err != ErrTimeout {
+		t.Errorf("want timeout error got %v", err)
+	}
+}
+
+// fakeConn answers status requests and fails to write jobs with err.
+type fakeConn struct {
+	err  error
+	resp []byte
+}
+
+func (c *fakeConn) Read(b []byte) (int, error) {
+	if len(c.resp) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, c.resp)
+	c.resp = c.resp[n:]
+	return n, nil
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	if string(b) == "\x1b!?" {
+		c.resp = append(c.resp, 0)
+		return len(b), nil
+	}
+	return 0, c.err
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func TestPrinterRetry(t *testing.T) {
+	var dials int
+	failDials := 2
+	p := &Printer{Retries: 2, Dial: func() (io.ReadWriteCloser, error) {
+		if dials++; dials <= failDials {
+			return nil, errors.New("dial failed")
+		}
+		return &fakeConn{err: errors.New("broken pipe")}, nil
+	}}
+	_, err := p.Status()
+	if err != nil || dials != 3 {
+		t.Errorf("want status after 3 dials got %d dials and error %v", dials, err)
+	}
+	dials, failDials = 0, 0
+	err = p.Send([]byte("CLS\n"))
+	var je *JobError
+	if !errors.As(err, &je) || dials != 1 {
+		t.Errorf("want job error after 1 dial got %d dials and error %v", dials, err)
+	}
+}
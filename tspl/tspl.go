@@ -18,45 +18,28 @@ func dot(f float64) int {
 }
 
 // RenderBfr renders the node n as TSPL to b or returns an error.
+// The output does not contain a PRINT command, use a Job to render complete print jobs.
 func RenderBfr(b bfr.B, man *font.Manager, n *layla.Node, extra ...string) error {
-	lay := &layla.Layouter{man, 'i', layla.FakeBoldStyler}
-	draw, err := lay.LayoutAndPage(n)
+	j := NewJob(man, extra...)
+	l, err := j.prepare(n)
 	if err != nil {
 		return err
 	}
-	w, h := n.W, n.H
-	if n.Rot == 90 {
-		w, h = h, w
-	}
-	fmt.Fprintf(b, "SIZE %g mm, %g mm\n", w/8, h/8)
-	fmt.Fprintf(b, "GAP %g mm, 0 mm\n", n.Gap/8)
-	b.WriteString("DIRECTION 1,0\nCODEPAGE UTF-8\n")
-	for _, line := range extra {
-		b.WriteString(line)
-		if len(line) > 0 && line[len(line)-1] != '\n' {
-			b.WriteByte('\n')
-		}
-	}
-	b.WriteString("CLS\n")
-	for _, d := range draw {
-		err = renderNode(lay, b, d, n.Rot, n.H)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return j.write(b, l)
 }
 
-func renderNode(lay *layla.Layouter, b bfr.B, d *layla.Node, rot int, rh float64) error {
-	if rot != 0 {
-		switch d.Kind {
-		case "rect", "line", "ellipse":
-			d.X, d.Y = rh-d.Y-d.H, d.X
-			d.W, d.H = d.H, d.W
-		case "text", "barcode", "qrcode":
-			d.X, d.Y = rh-d.Y, d.X
-		}
+// renderNode renders the display node d, that must already be rotated by layla.Rotate.
+func (j *Job) renderNode(b bfr.B, d *layla.Node) error {
+	if d.Kind == "text" && j.Text == TextBitmap && !counterRef(d.Data) {
+		return j.renderBitmap(b, d)
+	}
+	// text and codes are rotated by the printer around the anchor
+	rot := d.Rot
+	if rot%90 != 0 {
+		return fmt.Errorf("rotation %d of %s not supported", rot, d.Kind)
 	}
+	p := layla.Anchor(d.Box, rot)
+	c := layla.RotDim(d.Dim, rot)
 	switch d.Kind {
 	case "ellipse":
 		fmt.Fprintf(b, "ELLIPSE %d,%d,%d,%d,%d\n",
@@ -66,15 +49,32 @@ func renderNode(lay *layla.Layouter, b bfr.B, d *layla.Node, rot int, rh float64
 		fmt.Fprintf(b, "BOX %d,%d,%d,%d,%d\n",
 			dot(d.X), dot(d.Y), dot(d.X+d.W), dot(d.Y+d.H),
 			dot(d.Border.W))
+	case "debug":
+		// thermal printers have no colors, all debug boxes are drawn as thin outlines
+		fmt.Fprintf(b, "BOX %d,%d,%d,%d,1\n",
+			dot(d.X), dot(d.Y), dot(d.X+d.W), dot(d.Y+d.H))
+		if d.Data != "" {
+			fmt.Fprintf(b, "TEXT %d,%d,\"1\",%d,1,1,%q\n",
+				dot(p.X)+2, dot(p.Y)+2, rot, d.Data)
+		}
 	case "line":
 		fmt.Fprintf(b, "LINE %d,%d,%d,%d,%d\n",
 			dot(d.X), dot(d.Y), dot(d.X+d.W), dot(d.Y+d.H),
 			dot(d.Border.W))
 	case "text":
 		fsize := fontSize(d)
-		data := strings.Replace(fmt.Sprintf("%q", d.Data), "\\n", "\\[L]", -1)
-		space := math.Round(d.Font.Line - lay.PtToDot(d.Font.Height))
-		x, w := dot(d.X), dot(d.W)
+		if counterRef(d.Data) {
+			fmt.Fprintf(b, "TEXT %d,%d,%q,%d,%d,%d,%s\n",
+				dot(p.X), dot(p.Y), j.fontName(d), rot, fsize, fsize, d.Data)
+			break
+		}
+		data := d.Data
+		if !varRef(data) {
+			data = quote(data)
+		}
+		space := math.Round(d.Font.Line - j.PtToDot(d.Font.Height))
+		fname := j.fontName(d)
+		x, w := dot(p.X), dot(c.W)
 		// TODO fix overflow due to discrepancy between font measuring and printing
 		// the reason might be that the tsc printer does not apply kerning?
 		switch d.Align {
@@ -86,32 +86,55 @@ func renderNode(lay *layla.Layouter, b bfr.B, d *layla.Node, rot int, rh float64
 		default:
 			w += 10
 		}
-		fmt.Fprintf(b, "BLOCK %d,%d,%d,%d,\"0\",%d,%d,%d,%d,%d,%s\n",
-			x, dot(d.Y), w, dot(d.H), rot,
+		fmt.Fprintf(b, "BLOCK %d,%d,%d,%d,%q,%d,%d,%d,%d,%d,%s\n",
+			x, dot(p.Y), w, dot(c.H), fname, rot,
 			fsize, fsize, dot(space), d.Align, data)
 		if d.Font != nil && d.Font.Style&mark.B != 0 {
-			fmt.Fprintf(b, "BLOCK %d,%d,%d,%d,\"0\",%d,%d,%d,%d,%d,%s\n",
-				x+1, dot(d.Y), w+1, dot(d.H), rot,
+			// fake bold by printing again one dot further in text direction
+			o := layla.Rot{Deg: rot}.Pos(layla.Pos{X: 1})
+			fmt.Fprintf(b, "BLOCK %d,%d,%d,%d,%q,%d,%d,%d,%d,%d,%s\n",
+				x+dot(o.X), dot(p.Y+o.Y), w+1, dot(c.H), fname, rot,
 				fsize, fsize, dot(space), d.Align, data)
 		}
 	case "barcode":
-		h := dot(d.H)
+		h := dot(c.H)
 		if d.Code.Human != 0 {
 			h -= 20
 		}
-		fmt.Fprintf(b, "BARCODE %d,%d,%q,%d,%d,%d,%d,%d,%q\n",
-			dot(d.X), dot(d.Y), strings.ToUpper(d.Code.Name), h,
-			dot(d.Code.Wide), rot, d.Code.Human, d.Align, d.Data)
+		data := d.Data
+		if !counterRef(data) && !varRef(data) {
+			data = fmt.Sprintf("%q", data)
+		}
+		fmt.Fprintf(b, "BARCODE %d,%d,%q,%d,%d,%d,%d,%d,%s\n",
+			dot(p.X), dot(p.Y), codeType(d.Code.Name), h,
+			dot(d.Code.Wide), rot, d.Code.Human, d.Align, data)
 	case "qrcode":
-		fmt.Fprintf(b, "QRCODE %d,%d,%s,%d,A,%d,M2,S7,%q\n",
-			dot(d.X), dot(d.Y), strings.ToUpper(d.Code.Name),
-			dot(d.Code.Wide), rot, d.Data)
+		data := d.Data
+		if !varRef(data) {
+			data = fmt.Sprintf("%q", data)
+		}
+		fmt.Fprintf(b, "QRCODE %d,%d,%s,%d,A,%d,M2,S7,%s\n",
+			dot(p.X), dot(p.Y), strings.ToUpper(d.Code.Name),
+			dot(d.Code.Wide), rot, data)
 	default:
 		return fmt.Errorf("layout %s not supported", d.Kind)
 	}
 	return nil
 }
 
+// codeType returns the TSPL code type for a layla barcode name.
+func codeType(name string) string {
+	switch name {
+	case "code128":
+		return "128"
+	case "code39":
+		return "39"
+	case "itf":
+		return "25"
+	}
+	return strings.ToUpper(name)
+}
+
 func fontSize(n *layla.Node) (res int) {
 	if n.Font != nil {
 		res = dot(n.Font.Size)
@@ -17,18 +17,32 @@ func TestRenderNode(t *testing.T) {
 		raw  string
 		want string
 		rot  string
+		r180 string
+		r270 string
 	}{
 		{raw: "(box w:400 h:400 (rect x:100 y:80 w:60 h:40 border.w:1))",
 			want: "BOX 100,80,160,120,1\n",
 			rot:  "BOX 280,100,320,160,1\n",
+			r180: "BOX 240,280,300,320,1\n",
+			r270: "BOX 80,240,120,300,1\n",
 		},
 		{raw: "(box w:400 h:400 (ellipse x:100 y:80 w:60 h:40 border.w:2))",
 			want: "ELLIPSE 100,80,60,40,2\n",
 			rot:  "ELLIPSE 280,100,40,60,2\n",
+			r180: "ELLIPSE 240,280,60,40,2\n",
+			r270: "ELLIPSE 80,240,40,60,2\n",
+		},
+		{raw: "(box w:400 h:400 (barcode x:100 y:80 w:200 h:100 code:['ean13' 0 2] '4006381333931'))",
+			want: "BARCODE 100,80,\"EAN13\",100,2,0,0,0,\"4006381333931\"\n",
+			rot:  "BARCODE 320,100,\"EAN13\",100,2,90,0,0,\"4006381333931\"\n",
+			r180: "BARCODE 300,320,\"EAN13\",100,2,180,0,0,\"4006381333931\"\n",
+			r270: "BARCODE 80,300,\"EAN13\",100,2,270,0,0,\"4006381333931\"\n",
 		},
 		{raw: "(stage w:5000 h:800 (text align:2 font.size:32 'Smokey Mayonnaise'))",
 			want: "BLOCK -5,0,839,108,\"0\",0,32,32,18,2,\"Smokey Mayonnaise\"\n",
 		},
+		// the fake bold text is drawn twice with an offset of one dot in text direction,
+		// rotated text must not be offset across the text direction as x+1
 		{raw: "(box w:400 h:400 (markup `Test *Test* Test`))", want: "" +
 			"BLOCK 0,0,73,41,\"0\",0,8,8,7,0,\"Test\"\n" +
 			"BLOCK 71,0,74,41,\"0\",0,8,8,7,0,\"Test\"\n" +
@@ -36,52 +50,193 @@ func TestRenderNode(t *testing.T) {
 			"BLOCK 143,0,73,41,\"0\",0,8,8,7,0,\"Test\"\n", rot: "" +
 			"BLOCK 400,0,73,41,\"0\",90,8,8,7,0,\"Test\"\n" +
 			"BLOCK 400,71,74,41,\"0\",90,8,8,7,0,\"Test\"\n" +
-			"BLOCK 401,71,75,41,\"0\",90,8,8,7,0,\"Test\"\n" +
-			"BLOCK 400,143,73,41,\"0\",90,8,8,7,0,\"Test\"\n",
+			"BLOCK 400,72,75,41,\"0\",90,8,8,7,0,\"Test\"\n" +
+			"BLOCK 400,143,73,41,\"0\",90,8,8,7,0,\"Test\"\n", r180: "" +
+			"BLOCK 400,400,73,41,\"0\",180,8,8,7,0,\"Test\"\n" +
+			"BLOCK 329,400,74,41,\"0\",180,8,8,7,0,\"Test\"\n" +
+			"BLOCK 328,400,75,41,\"0\",180,8,8,7,0,\"Test\"\n" +
+			"BLOCK 257,400,73,41,\"0\",180,8,8,7,0,\"Test\"\n", r270: "" +
+			"BLOCK 0,400,73,41,\"0\",270,8,8,7,0,\"Test\"\n" +
+			"BLOCK 0,329,74,41,\"0\",270,8,8,7,0,\"Test\"\n" +
+			"BLOCK 0,328,75,41,\"0\",270,8,8,7,0,\"Test\"\n" +
+			"BLOCK 0,257,73,41,\"0\",270,8,8,7,0,\"Test\"\n",
 		},
 	}
 	for _, test := range tests {
-		got, err := render(man, test.raw, false, 400)
-		if err != nil {
-			t.Errorf("rot %v", err)
-			continue
-		}
-		if got != test.want {
-			t.Errorf("want: %s\ngot:  %s", test.want, got)
-		}
-		if test.rot != "" {
-			got, err = render(man, test.raw, true, 400)
+		for i, want := range []string{test.want, test.rot, test.r180, test.r270} {
+			if want == "" {
+				continue
+			}
+			got, err := render(man, test.raw, i*90)
 			if err != nil {
-				t.Errorf("render %v", err)
+				t.Errorf("render %d %v", i*90, err)
 				continue
 			}
-			if got != test.rot {
-				t.Errorf("want: %s\ngot:  %s", test.rot, got)
+			if got != want {
+				t.Errorf("rot %d want: %s\ngot:  %s", i*90, want, got)
 			}
 		}
 	}
 }
 
-func render(man *font.Manager, raw string, rot bool, h float64) (string, error) {
+func render(man *font.Manager, raw string, deg int) (string, error) {
 	node, err := layla.Execute(layla.Env, strings.NewReader(raw))
 	if err != nil {
 		return "", err
 	}
-	lay := &layla.Layouter{man, 'i', layla.FakeBoldStyler}
-	draw, err := lay.LayoutAndPage(node)
+	j := NewJob(man)
+	draw, err := j.LayoutAndPage(node)
+	if err != nil {
+		return "", err
+	}
+	_, err = layla.Rotate(draw, node.Dim, deg)
 	if err != nil {
 		return "", err
 	}
 	var b strings.Builder
 	for _, d := range draw {
-		deg := 0
-		if rot {
-			deg = 90
-		}
-		err = renderNode(lay, &b, d, deg, h)
+		err = j.renderNode(&b, d)
 		if err != nil {
 			return "", err
 		}
 	}
 	return b.String(), nil
 }
+
+func TestJob(t *testing.T) {
+	man := font.NewManager(200, 1, 1).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	label := func(data string) *layla.Node {
+		return &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 400, H: 240}},
+			List: []*layla.Node{
+				{Kind: "text", Data: data},
+				{Kind: "barcode", Box: layla.Box{Pos: layla.Pos{Y: 60}, Dim: layla.Dim{H: 100}},
+					Code: &layla.Code{Name: "ean128", Wide: 2}, Data: "@0"},
+			},
+		}
+	}
+	j := NewJob(man)
+	j.Counters = []Counter{{Start: "0001", Step: 1}}
+	var b strings.Builder
+	for _, data := range []string{"A", "@0"} {
+		err := j.Label(&b, label(data), 2, 3)
+		if err != nil {
+			t.Fatalf("label error: %v", err)
+		}
+	}
+	want := "" +
+		"SIZE 50 mm, 30 mm\nGAP 0 mm, 0 mm\nDIRECTION 1,0\nCODEPAGE UTF-8\n" +
+		"SET COUNTER @0 1\n@0 = \"0001\"\n" +
+		"CLS\n" +
+		"BLOCK 0,0,32,41,\"0\",0,8,8,7,0,\"A\"\n" +
+		"BARCODE 0,60,\"EAN128\",100,2,0,0,0,@0\n" +
+		"PRINT 2,3\n" +
+		"CLS\n" +
+		"TEXT 0,0,\"0\",0,8,8,@0\n" +
+		"BARCODE 0,60,\"EAN128\",100,2,0,0,0,@0\n" +
+		"PRINT 2,3\n"
+	if got := b.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestTextMode(t *testing.T) {
+	man := font.NewManager(200, 1, 1).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	label := func() *layla.Node {
+		return &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 400, H: 240}},
+			List: []*layla.Node{{Kind: "text", Data: "Test"}},
+		}
+	}
+	j := NewJob(man)
+	j.Text = TextDownload
+	var b strings.Builder
+	for i := 0; i < 2; i++ {
+		err := j.Label(&b, label(), 1, 1)
+		if err != nil {
+			t.Fatalf("label error: %v", err)
+		}
+	}
+	got := b.String()
+	if c := strings.Count(got, "DOWNLOAD \"LAYLA.TTF\","); c != 1 {
+		t.Errorf("want one font download got %d", c)
+	}
+	if !strings.Contains(got, "BLOCK 0,0,73,41,\"LAYLA.TTF\",0,8,8,7,0,\"Test\"\n") {
+		t.Errorf("want block with downloaded font")
+	}
+	j = NewJob(man)
+	j.Text = TextBitmap
+	b.Reset()
+	err := j.Label(&b, label(), 1, 1)
+	if err != nil {
+		t.Fatalf("label error: %v", err)
+	}
+	got = b.String()
+	i := strings.Index(got, "BITMAP 0,0,8,41,0,")
+	if i < 0 {
+		t.Fatalf("want bitmap command got %q", got)
+	}
+	data := got[i+len("BITMAP 0,0,8,41,0,"):]
+	if len(data) < 328 || strings.Count(data[:328], "\xff") == 328 {
+		t.Errorf("want 328 bytes of bitmap data with black dots")
+	}
+	if strings.Contains(got, "BLOCK") {
+		t.Errorf("want no block command in bitmap mode")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	man := font.NewManager(200, 1, 1).RegisterTTF("", "../testdata/font/Go-Regular.ttf")
+	if err := man.Err(); err != nil {
+		t.Fatal(err)
+	}
+	label := func(data, code string) *layla.Node {
+		return &layla.Node{Kind: "stage", Box: layla.Box{Dim: layla.Dim{W: 400, H: 240}},
+			List: []*layla.Node{
+				{Kind: "text", Box: layla.Box{Dim: layla.Dim{W: 200}}, Data: data},
+				{Kind: "barcode", Box: layla.Box{Pos: layla.Pos{Y: 60}, Dim: layla.Dim{H: 100}},
+					Code: &layla.Code{Name: "ean128", Wide: 2}, Data: code},
+			},
+		}
+	}
+	j := NewJob(man)
+	j.Counters = []Counter{{Start: "0001", Step: 1}}
+	var b strings.Builder
+	err := j.Batch(&b, []*layla.Node{label("A", "@0"), label("B", "@0")}, 2)
+	if err != nil {
+		t.Fatalf("batch error: %v", err)
+	}
+	want := "" +
+		"SIZE 50 mm, 30 mm\nGAP 0 mm, 0 mm\nDIRECTION 1,0\nCODEPAGE UTF-8\n" +
+		"SET COUNTER @0 1\n@0 = \"0001\"\n" +
+		"DOWNLOAD \"LAYLA.BAS\"\n" +
+		"CLS\n" +
+		"BLOCK 0,0,210,41,\"0\",0,8,8,7,0,V0$\n" +
+		"BARCODE 0,60,\"EAN128\",100,2,0,0,0,@0\n" +
+		"PRINT 1,2\n" +
+		"EOP\n" +
+		"V0$ = \"A\"\nRUN \"LAYLA.BAS\"\n" +
+		"V0$ = \"B\"\nRUN \"LAYLA.BAS\"\n"
+	if got := b.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+	b.Reset()
+	err = NewJob(man).Batch(&b, []*layla.Node{label("A", "1"), label("A\nB", "2")}, 1)
+	if err != nil {
+		t.Fatalf("batch error: %v", err)
+	}
+	if got := b.String(); strings.Contains(got, "LAYLA.BAS") || strings.Count(got, "CLS\n") != 2 {
+		t.Errorf("want two full labels for different layouts got:\n%s", got)
+	}
+	j = NewJob(man)
+	j.Counters = make([]Counter, 11)
+	b.Reset()
+	err = j.Batch(&b, []*layla.Node{label("A", "@0")}, 1)
+	if err == nil || b.Len() != 0 {
+		t.Errorf("want counter error without output got %v and %q", err, b.String())
+	}
+}